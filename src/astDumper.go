@@ -0,0 +1,506 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A node of the AST as it is dumped, with its fields in the order they are written
+type astNode struct {
+	kind   string
+	line   int // 0 if the node has no position
+	col    int // 0 if only the line of the node is known
+	fields []astField
+}
+
+// A named field of a dumped node, holding a string, bool, literal value, node, or list of nodes
+type astField struct {
+	name  string
+	value any
+}
+
+// A literal value held by a field, which is written as it would be in the source
+type astLiteral struct {
+	value any
+}
+
+// Visitor pattern that converts statements and expressions into nodes that can be dumped
+type AstDumper struct {
+	lines map[Stmt]int // Lines that statements start on, for statements without tokens
+}
+
+// Test for interface implementation
+var _ ExprVisitor = &AstDumper{}
+var _ StmtVisitor = &AstDumper{}
+
+// Entry point for dumping, which converts each statement
+func (d *AstDumper) dump(statements []Stmt) []*astNode {
+	return d.stmts(statements)
+}
+
+// Build a node positioned at a token
+func tokenNode(kind string, token Token, fields ...astField) *astNode {
+	return &astNode{kind: kind, line: token.line, col: token.col, fields: fields}
+}
+
+// Build a node positioned at the line a statement starts on
+func (d *AstDumper) lineNode(kind string, stmt Stmt, fields ...astField) *astNode {
+	return &astNode{kind: kind, line: d.lines[stmt], fields: fields}
+}
+
+// Convert a statement, or nil if there is none
+func (d *AstDumper) stmt(stmt Stmt) any {
+	if stmt == nil {
+		return nil
+	}
+	return stmt.accept(d)
+}
+
+// Convert an expression, or nil if there is none
+func (d *AstDumper) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+	return expr.accept(d)
+}
+
+// Convert a list of statements
+func (d *AstDumper) stmts(statements []Stmt) []*astNode {
+	nodes := []*astNode{}
+	for _, statement := range statements {
+		nodes = append(nodes, statement.accept(d).(*astNode))
+	}
+	return nodes
+}
+
+// Convert a list of expressions
+func (d *AstDumper) exprs(expressions []Expr) []*astNode {
+	nodes := []*astNode{}
+	for _, expression := range expressions {
+		nodes = append(nodes, expression.accept(d).(*astNode))
+	}
+	return nodes
+}
+
+// Convert a list of functions
+func (d *AstDumper) functions(functions []*FunctionStmt) []*astNode {
+	nodes := []*astNode{}
+	for _, function := range functions {
+		nodes = append(nodes, d.visitFunctionStmt(function).(*astNode))
+	}
+	return nodes
+}
+
+// Convert a type annotation, or nil if there is none
+func annotationField(name string, annotation *TypeExpr) astField {
+	if annotation == nil {
+		return astField{name, nil}
+	}
+	return astField{name, annotation.String()}
+}
+
+// Convert a pattern
+func (d *AstDumper) pattern(pattern Pattern) *astNode {
+	switch pattern := pattern.(type) {
+	case *BindingPattern:
+		return tokenNode("BindingPattern", pattern.name, astField{"name", pattern.name.lexeme})
+	case *LiteralPattern:
+		return tokenNode("LiteralPattern", pattern.token, astField{"value", astLiteral{pattern.value}})
+	case *ListPattern:
+		elements := []*astNode{}
+		for _, element := range pattern.elements {
+			elements = append(elements, d.pattern(element))
+		}
+		var rest any
+		if pattern.rest != nil {
+			rest = d.pattern(pattern.rest)
+		}
+		return tokenNode("ListPattern", pattern.bracket, astField{"elements", elements}, astField{"rest", rest})
+	case *ClassPattern:
+		args := []*astNode{}
+		for _, arg := range pattern.args {
+			args = append(args, d.pattern(arg))
+		}
+		return tokenNode("ClassPattern", pattern.paren, astField{"class", pattern.class.lexeme},
+			astField{"args", args})
+	case *ObjectPattern:
+		fields := []*astNode{}
+		for _, field := range pattern.fields {
+			fields = append(fields, tokenNode("FieldPattern", field.name,
+				astField{"name", field.name.lexeme}, astField{"pattern", d.pattern(field.pattern)}))
+		}
+		return tokenNode("ObjectPattern", pattern.brace, astField{"fields", fields})
+	}
+	return nil
+}
+
+// Convert the statements of a block
+func (d *AstDumper) visitBlockStmt(stmt *BlockStmt) any {
+	return &astNode{kind: "Block", fields: []astField{{"statements", d.stmts(stmt.statements)}}}
+}
+
+// Convert the traits and members of a class
+func (d *AstDumper) visitClassStmt(stmt *ClassStmt) any {
+	traits := []*astNode{}
+	for _, trait := range stmt.traits {
+		traits = append(traits, d.visitVariableExpr(trait).(*astNode))
+	}
+	fields := []*astNode{}
+	for _, field := range stmt.staticFields {
+		fields = append(fields, d.visitVarStmt(field).(*astNode))
+	}
+	return tokenNode("Class", stmt.name, astField{"name", stmt.name.lexeme}, astField{"traits", traits},
+		astField{"methods", d.functions(stmt.methods)}, astField{"setters", d.functions(stmt.setters)},
+		astField{"staticMethods", d.functions(stmt.staticMethods)}, astField{"staticFields", fields})
+}
+
+// Convert the expression
+func (d *AstDumper) visitExpressionStmt(stmt *ExpressionStmt) any {
+	return d.lineNode("Expression", stmt, astField{"expression", d.expr(stmt.expression)})
+}
+
+// Convert the loop variable or pattern, the iterable, and the body
+func (d *AstDumper) visitForInStmt(stmt *ForInStmt) any {
+	var pattern any
+	if stmt.pattern != nil {
+		pattern = d.pattern(stmt.pattern)
+	}
+	return tokenNode("ForIn", stmt.name, astField{"name", stmt.name.lexeme}, astField{"pattern", pattern},
+		astField{"iterable", d.expr(stmt.iterable)}, astField{"body", d.stmt(stmt.body)})
+}
+
+// Convert the parameters and body of a function
+func (d *AstDumper) visitFunctionStmt(stmt *FunctionStmt) any {
+	params := []*astNode{}
+	for _, param := range stmt.params {
+		params = append(params, tokenNode("Param", param.name, astField{"name", param.name.lexeme},
+			annotationField("annotation", param.annotation), astField{"default", d.expr(param.defaultValue)},
+			astField{"rest", param.isRest}))
+	}
+	return tokenNode("Function", stmt.name, astField{"name", stmt.name.lexeme},
+		astField{"decorators", d.exprs(stmt.decorators)}, astField{"params", params},
+		annotationField("returnType", stmt.returnType), astField{"generator", stmt.isGenerator},
+		astField{"getter", stmt.isGetter}, astField{"body", d.stmts(stmt.body)})
+}
+
+// Convert the condition and branches
+func (d *AstDumper) visitIfStmt(stmt *IfStmt) any {
+	return d.lineNode("If", stmt, astField{"condition", d.expr(stmt.condition)},
+		astField{"then", d.stmt(stmt.thenBranch)}, astField{"else", d.stmt(stmt.elseBranch)})
+}
+
+// Convert the return value
+func (d *AstDumper) visitReturnStmt(stmt *ReturnStmt) any {
+	return tokenNode("Return", stmt.keyword, astField{"value", d.expr(stmt.value)},
+		astField{"tailCall", stmt.isTailCall})
+}
+
+// Convert the required and default methods of a trait
+func (d *AstDumper) visitTraitStmt(stmt *TraitStmt) any {
+	return tokenNode("Trait", stmt.name, astField{"name", stmt.name.lexeme},
+		astField{"required", d.functions(stmt.required)}, astField{"methods", d.functions(stmt.methods)})
+}
+
+// Convert the variable or pattern, and the initializer
+func (d *AstDumper) visitVarStmt(stmt *VarStmt) any {
+	var pattern any
+	if stmt.pattern != nil {
+		pattern = d.pattern(stmt.pattern)
+	}
+	return tokenNode("Var", stmt.name, astField{"name", stmt.name.lexeme}, astField{"pattern", pattern},
+		annotationField("annotation", stmt.annotation), astField{"initializer", d.expr(stmt.initializer)},
+		astField{"constant", stmt.constant})
+}
+
+// Convert the condition and the body
+func (d *AstDumper) visitWhileStmt(stmt *WhileStmt) any {
+	return d.lineNode("While", stmt, astField{"condition", d.expr(stmt.condition)},
+		astField{"body", d.stmt(stmt.body)})
+}
+
+// Convert the variable and value
+func (d *AstDumper) visitAssignExpr(expr *AssignExpr) any {
+	return tokenNode("Assign", expr.name, astField{"name", expr.name.lexeme}, astField{"value", d.expr(expr.value)})
+}
+
+// Convert the operator and operands
+func (d *AstDumper) visitBinaryExpr(expr *BinaryExpr) any {
+	return tokenNode("Binary", expr.operator, astField{"operator", expr.operator.lexeme},
+		astField{"left", d.expr(expr.left)}, astField{"right", d.expr(expr.right)})
+}
+
+// Convert the callee and the arguments (positional and keyword)
+func (d *AstDumper) visitCallExpr(expr *CallExpr) any {
+	keywords := []*astNode{}
+	for _, keyword := range expr.keywords {
+		keywords = append(keywords, tokenNode("Keyword", keyword.name, astField{"name", keyword.name.lexeme},
+			astField{"value", d.expr(keyword.value)}))
+	}
+	return tokenNode("Call", expr.paren, astField{"callee", d.expr(expr.callee)},
+		astField{"arguments", d.exprs(expr.arguments)}, astField{"keywords", keywords},
+		astField{"optional", expr.optional})
+}
+
+// Convert the chain
+func (d *AstDumper) visitChainExpr(expr *ChainExpr) any {
+	return &astNode{kind: "Chain", fields: []astField{{"expression", d.expr(expr.expression)}}}
+}
+
+// Convert the targets and value
+func (d *AstDumper) visitDestructureExpr(expr *DestructureExpr) any {
+	return tokenNode("Destructure", expr.equals, astField{"targets", d.exprs(expr.targets)},
+		astField{"value", d.expr(expr.value)})
+}
+
+// Convert the object and property name
+func (d *AstDumper) visitGetExpr(expr *GetExpr) any {
+	return tokenNode("Get", expr.name, astField{"object", d.expr(expr.object)},
+		astField{"name", expr.name.lexeme}, astField{"optional", expr.optional})
+}
+
+// Convert the expression
+func (d *AstDumper) visitGroupingExpr(expr *GroupingExpr) any {
+	return &astNode{kind: "Grouping", fields: []astField{{"expression", d.expr(expr.expression)}}}
+}
+
+// Convert the indexee and the indices
+func (d *AstDumper) visitIndexExpr(expr *IndexExpr) any {
+	return tokenNode("Index", expr.bracket, astField{"indexee", d.expr(expr.indexee)},
+		astField{"start", d.expr(expr.start)}, astField{"stop", d.expr(expr.stop)},
+		astField{"optional", expr.optional})
+}
+
+// Convert each element
+func (d *AstDumper) visitListExpr(expr *ListExpr) any {
+	return tokenNode("List", expr.bracket, astField{"elements", d.exprs(expr.elements)})
+}
+
+// Convert the value, which has no position
+func (*AstDumper) visitLiteralExpr(expr *LiteralExpr) any {
+	return tokenNode("Literal", expr.token, astField{"value", astLiteral{expr.value}})
+}
+
+// Convert the operator and operands
+func (d *AstDumper) visitLogicalExpr(expr *LogicalExpr) any {
+	return tokenNode("Logical", expr.operator, astField{"operator", expr.operator.lexeme},
+		astField{"left", d.expr(expr.left)}, astField{"right", d.expr(expr.right)})
+}
+
+// Convert the subject and each arm, where the default arm has no patterns
+func (d *AstDumper) visitMatchExpr(expr *MatchExpr) any {
+	arms := []*astNode{}
+	for _, arm := range expr.arms {
+		patterns := []*astNode{}
+		for _, pattern := range arm.patterns {
+			patterns = append(patterns, d.pattern(pattern))
+		}
+		arms = append(arms, &astNode{kind: "Arm", fields: []astField{{"default", arm.patterns == nil},
+			{"patterns", patterns}, {"guard", d.expr(arm.guard)}, {"body", d.stmt(arm.body)},
+			{"value", d.expr(arm.value)}}})
+	}
+	return tokenNode("Match", expr.keyword, astField{"subject", d.expr(expr.subject)}, astField{"arms", arms})
+}
+
+// Convert the indexee, index, and value
+func (d *AstDumper) visitReplaceExpr(expr *ReplaceExpr) any {
+	return tokenNode("Replace", expr.bracket, astField{"indexee", d.expr(expr.indexee)},
+		astField{"index", d.expr(expr.index)}, astField{"value", d.expr(expr.value)})
+}
+
+// Convert the object, property name, and value
+func (d *AstDumper) visitSetExpr(expr *SetExpr) any {
+	return tokenNode("Set", expr.name, astField{"object", d.expr(expr.object)},
+		astField{"name", expr.name.lexeme}, astField{"value", d.expr(expr.value)})
+}
+
+// Convert the call
+func (d *AstDumper) visitSpawnExpr(expr *SpawnExpr) any {
+	return tokenNode("Spawn", expr.keyword, astField{"call", d.expr(expr.call)})
+}
+
+// Convert the spread expression
+func (d *AstDumper) visitSpreadExpr(expr *SpreadExpr) any {
+	return tokenNode("Spread", expr.ellipsis, astField{"expression", d.expr(expr.expression)})
+}
+
+// Convert the condition and both values
+func (d *AstDumper) visitTernaryExpr(expr *TernaryExpr) any {
+	return tokenNode("Ternary", expr.operator, astField{"condition", d.expr(expr.condition)},
+		astField{"trueValue", d.expr(expr.trueValue)}, astField{"falseValue", d.expr(expr.falseValue)})
+}
+
+// Convert "this"
+func (*AstDumper) visitThisExpr(expr *ThisExpr) any {
+	return tokenNode("This", expr.Token)
+}
+
+// Convert the operator and operand
+func (d *AstDumper) visitUnaryExpr(expr *UnaryExpr) any {
+	return tokenNode("Unary", expr.operator, astField{"operator", expr.operator.lexeme},
+		astField{"operand", d.expr(expr.operand)})
+}
+
+// Convert the variable name
+func (*AstDumper) visitVariableExpr(expr *VariableExpr) any {
+	return tokenNode("Variable", expr.Token, astField{"name", expr.lexeme})
+}
+
+// Convert the yielded value
+func (d *AstDumper) visitYieldExpr(expr *YieldExpr) any {
+	return tokenNode("Yield", expr.keyword, astField{"value", d.expr(expr.value)})
+}
+
+// Write nodes as S-expressions, one top-level node after another
+// Simple fields are written on the line of their node, and nested nodes on their own indented lines
+func writeSexpr(nodes []*astNode) string {
+	var builder strings.Builder
+	for _, node := range nodes {
+		node.writeSexpr(&builder, "")
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// Write a node as an S-expression at a given level of indentation
+func (n *astNode) writeSexpr(builder *strings.Builder, prefix string) {
+	builder.WriteString("(" + n.kind)
+	if n.line > 0 {
+		builder.WriteString(" " + n.position())
+	}
+
+	// Missing children, empty lists, and false flags are left out to keep the output short
+	nested := []astField{}
+	for _, field := range n.fields {
+		switch value := field.value.(type) {
+		case nil:
+		case bool:
+			if value {
+				builder.WriteString(" " + field.name)
+			}
+		case string:
+			builder.WriteString(" " + field.name + "=" + strconv.Quote(value))
+		case astLiteral:
+			builder.WriteString(" " + field.name + "=" + value.String())
+		case []*astNode:
+			if len(value) > 0 {
+				nested = append(nested, field)
+			}
+		case *astNode:
+			if value != nil {
+				nested = append(nested, field)
+			}
+		}
+	}
+
+	for _, field := range nested {
+		builder.WriteString("\n" + prefix + "  :" + field.name + " ")
+		switch value := field.value.(type) {
+		case *astNode:
+			value.writeSexpr(builder, prefix+"  ")
+		case []*astNode:
+			builder.WriteString("[")
+			for _, node := range value {
+				builder.WriteString("\n" + prefix + "    ")
+				node.writeSexpr(builder, prefix+"    ")
+			}
+			builder.WriteString("]")
+		}
+	}
+	builder.WriteString(")")
+}
+
+// Line and column of a node, or only the line if the column is not known
+func (n *astNode) position() string {
+	if n.col == 0 {
+		return strconv.Itoa(n.line)
+	}
+	return strconv.Itoa(n.line) + ":" + strconv.Itoa(n.col)
+}
+
+// Write a literal value as it would appear in the source, keeping floats distinct from integers
+func (l astLiteral) String() string {
+	switch value := l.value.(type) {
+	case nil:
+		return "nil"
+	case Sequence:
+		return strconv.Quote(value.toGoString())
+	case float64:
+		str := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(str, ".eIN") {
+			str += ".0"
+		}
+		return str
+	case *big.Int:
+		return value.String()
+	}
+	return fmt.Sprint(l.value)
+}
+
+// Write nodes as a JSON array, with the fields of each node in order
+func writeAstJson(nodes []*astNode) string {
+	encoder := &JsonEncoder{indent: "  ", visiting: map[any]bool{}}
+	encoder.encodeNodes(nodes, "")
+	return encoder.builder.String() + "\n"
+}
+
+// Write a list of nodes as a JSON array
+func (e *JsonEncoder) encodeNodes(nodes []*astNode, prefix string) {
+	e.builder.WriteString("[")
+	for j, node := range nodes {
+		if j != 0 {
+			e.builder.WriteString(",")
+		}
+		e.newline(prefix + e.indent)
+		e.encodeNode(node, prefix+e.indent)
+	}
+	if len(nodes) > 0 {
+		e.newline(prefix)
+	}
+	e.builder.WriteString("]")
+}
+
+// Write a node as a JSON object, with its kind and position before its fields
+func (e *JsonEncoder) encodeNode(node *astNode, prefix string) {
+	e.builder.WriteString("{")
+	e.newline(prefix + e.indent)
+	e.builder.WriteString("\"node\": ")
+	e.encodeString(node.kind)
+	if node.line > 0 {
+		e.builder.WriteString(",")
+		e.newline(prefix + e.indent)
+		e.builder.WriteString("\"line\": " + strconv.Itoa(node.line))
+	}
+	if node.col > 0 {
+		e.builder.WriteString(",")
+		e.newline(prefix + e.indent)
+		e.builder.WriteString("\"col\": " + strconv.Itoa(node.col))
+	}
+
+	for _, field := range node.fields {
+		e.builder.WriteString(",")
+		e.newline(prefix + e.indent)
+		e.encodeString(field.name)
+		e.builder.WriteString(": ")
+		switch value := field.value.(type) {
+		case *astNode:
+			if value == nil {
+				e.builder.WriteString("null")
+			} else {
+				e.encodeNode(value, prefix+e.indent)
+			}
+		case []*astNode:
+			e.encodeNodes(value, prefix+e.indent)
+		case astLiteral:
+			e.encode(value.value, prefix+e.indent)
+		case string:
+			e.encodeString(value)
+		default:
+			e.encode(value, prefix+e.indent)
+		}
+	}
+	e.newline(prefix)
+	e.builder.WriteString("}")
+}
@@ -4,6 +4,6 @@ package main
 // Any object that can be called with parentheses
 type Callable interface {
 	toString() string                                   // string representation
-	arity() int                                         // number of paremeters
+	arity() (int, int)                                  // minimum and maximum number of arguments
 	call(interpreter *Interpreter, arguments []any) any // functionality of the call
 }
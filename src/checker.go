@@ -0,0 +1,775 @@
+// Ward Jaeger, CS 403
+package main
+
+// A variable as the type checker sees it
+type checkVariable struct {
+	name     Token
+	declared *TypeExpr     // Type the variable is annotated with, or nil
+	inferred *TypeExpr     // Type of what the variable holds, as far as it is known
+	function *FunctionStmt // Function the variable is declared as, whose parameters calls are checked against
+	class    *ClassStmt    // Class the variable is declared as, whose initializer calls are checked against
+}
+
+// Visitor pattern that infers the types of expressions from literals and annotations,
+// and reports values used where their types can't be
+type TypeChecker struct {
+	scopes          []map[string]*checkVariable
+	classes         map[string]*ClassStmt
+	traits          map[string]*TraitStmt
+	widened         map[Token]bool // Unannotated variables assigned values of other types, whose types are unknown
+	currentFunction *FunctionStmt  // Innermost function, whose return type return values are checked against
+	currentClass    *ClassStmt     // Innermost class, which is the type of "this"
+	reporting       bool           // Whether errors are reported, which they are only on the second pass
+}
+
+// Test for interface implementation
+var _ ExprVisitor = &TypeChecker{}
+var _ StmtVisitor = &TypeChecker{}
+
+// Types that natives are known to return
+var nativeReturnTypes = map[string]string{"clock": "float", "len": "int", "toNumber": "number", "float": "float",
+	"int": "int", "toString": "string", "implements": "bool"}
+
+// Build a type checker
+func newTypeChecker() *TypeChecker {
+	return &TypeChecker{classes: map[string]*ClassStmt{}, traits: map[string]*TraitStmt{}, widened: map[Token]bool{}}
+}
+
+// Entry point for type checking, which walks the script twice
+// The first pass finds the classes and traits, and the variables assigned values of other types,
+// so that the second pass knows them before they are declared
+func (c *TypeChecker) check(statements []Stmt) {
+	for _, reporting := range []bool{false, true} {
+		c.reporting = reporting
+		c.beginScope()
+		for _, statement := range statements {
+			switch stmt := statement.(type) {
+			case *ClassStmt:
+				c.declare(&checkVariable{name: stmt.name, inferred: namedType("class"), class: stmt})
+			case *FunctionStmt:
+				c.declare(functionVariable(stmt))
+			case *TraitStmt:
+				c.declare(&checkVariable{name: stmt.name, inferred: namedType("trait")})
+			case *VarStmt:
+				for _, name := range varNames(stmt) {
+					c.declare(&checkVariable{name: name, declared: stmt.annotation, inferred: stmt.annotation})
+				}
+			}
+		}
+		c.checkStatements(statements)
+		c.scopes = nil
+	}
+}
+
+// A built-in type, or an instance of a class
+func namedType(name string) *TypeExpr {
+	return &TypeExpr{name: Token{tokenType: IDENTIFIER, lexeme: name}}
+}
+
+// Check whether nothing is known about a type
+func isAny(t *TypeExpr) bool {
+	return t == nil || t.name.lexeme == "any"
+}
+
+// Check whether values of a type are always numbers
+func isNumberType(t *TypeExpr) bool {
+	return !t.nullable && (t.name.lexeme == "number" || t.name.lexeme == "int" || t.name.lexeme == "float")
+}
+
+// Check whether values of a type are known not to be instances, which could overload operators
+func isPrimitive(t *TypeExpr) bool {
+	if isAny(t) || t.nullable {
+		return false
+	}
+	switch t.name.lexeme {
+	case "nil", "bool", "number", "int", "float", "string", "list", "function", "class", "trait":
+		return true
+	}
+	return false
+}
+
+// Report an error, but only on the second pass
+func (c *TypeChecker) error(token Token, message string) {
+	if c.reporting {
+		reportToken(token, message)
+	}
+}
+
+// Check whether a value of one type can be used where another type is expected
+// Unknown types can be used anywhere, since the checker only reports what it is sure of
+func (c *TypeChecker) assignable(from *TypeExpr, to *TypeExpr) bool {
+	if isAny(from) || isAny(to) || !c.known(from) || !c.known(to) {
+		return true
+	}
+	if from.name.lexeme == "nil" {
+		return to.nullable || to.name.lexeme == "nil"
+	}
+	if from.nullable && !to.nullable {
+		return false
+	}
+
+	switch to.name.lexeme {
+	case "number":
+		return isNumberType(&TypeExpr{name: from.name})
+	case "list":
+		return from.name.lexeme == "list" && (from.element == nil || to.element == nil ||
+			c.assignable(from.element, to.element))
+	case "instance":
+		_, isClass := c.classes[from.name.lexeme]
+		return isClass || from.name.lexeme == "instance"
+	}
+	if trait, ok := c.traits[to.name.lexeme]; ok {
+		if class, ok := c.classes[from.name.lexeme]; ok {
+			return c.implements(class, trait)
+		}
+	}
+	return from.name.lexeme == to.name.lexeme
+}
+
+// Check whether a class includes a trait, or has every method of it anyway
+func (c *TypeChecker) implements(class *ClassStmt, trait *TraitStmt) bool {
+	methods := map[string]bool{}
+	for _, included := range class.traits {
+		if included.lexeme == trait.name.lexeme {
+			return true
+		}
+		if other, ok := c.traits[included.lexeme]; ok {
+			for _, method := range other.methods {
+				methods[method.name.lexeme] = true
+			}
+		}
+	}
+	for _, method := range class.methods {
+		methods[method.name.lexeme] = true
+	}
+	for _, members := range [][]*FunctionStmt{trait.required, trait.methods} {
+		for _, method := range members {
+			if !methods[method.name.lexeme] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Check whether a type is built in, or names a class or trait
+func (c *TypeChecker) known(t *TypeExpr) bool {
+	_, isClass := c.classes[t.name.lexeme]
+	_, isTrait := c.traits[t.name.lexeme]
+	return isClass || isTrait || containsString(builtinTypes, t.name.lexeme)
+}
+
+// Report an annotation that names neither a built-in type nor a class or trait
+func (c *TypeChecker) checkAnnotation(t *TypeExpr) {
+	if t == nil {
+		return
+	}
+	if !c.known(t) {
+		c.error(t.name, "Unknown type '"+t.name.lexeme+"'.")
+	}
+	c.checkAnnotation(t.element)
+}
+
+// Report a value whose type can't be used where an annotated type is expected
+func (c *TypeChecker) expect(token Token, expected *TypeExpr, actual *TypeExpr, what string) {
+	if expected != nil && !c.assignable(actual, expected) {
+		c.error(token, "Expect "+expected.String()+" for "+what+" but got "+actual.describe(expected)+".")
+	}
+}
+
+// Pass type checker to statements and expressions
+func (c *TypeChecker) checkStmt(stmt Stmt) {
+	stmt.accept(c)
+}
+func (c *TypeChecker) typeOf(expr Expr) *TypeExpr {
+	if t, ok := expr.accept(c).(*TypeExpr); ok && t != nil {
+		return t
+	}
+	return namedType("any")
+}
+
+// Check a list of statements
+func (c *TypeChecker) checkStatements(statements []Stmt) {
+	for _, statement := range statements {
+		c.checkStmt(statement)
+	}
+}
+
+// Creates an additional scope one level deeper
+func (c *TypeChecker) beginScope() {
+	c.scopes = append(c.scopes, map[string]*checkVariable{})
+}
+
+// Removes the most recent scope
+func (c *TypeChecker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// Declare a variable in the current scope
+// Globals are declared before checking begins, so only their inferred types are updated
+// Variables that are later assigned values of other types are never inferred
+func (c *TypeChecker) declare(variable *checkVariable) {
+	if c.widened[variable.name] {
+		variable.inferred, variable.function, variable.class = variable.declared, nil, nil
+	}
+	scope := c.scopes[len(c.scopes)-1]
+	if existing, found := scope[variable.name.lexeme]; found && existing.name == variable.name {
+		existing.inferred, existing.function, existing.class = variable.inferred, variable.function, variable.class
+		return
+	}
+	scope[variable.name.lexeme] = variable
+}
+
+// Find the innermost variable with a given name
+func (c *TypeChecker) lookup(name string) *checkVariable {
+	for j := len(c.scopes) - 1; j >= 0; j-- {
+		if variable, found := c.scopes[j][name]; found {
+			return variable
+		}
+	}
+	return nil
+}
+
+// Note an assignment to a variable, which must match its annotation,
+// or else makes its type unknown unless the value is known to match what it held before
+func (c *TypeChecker) assign(name Token, value *TypeExpr) {
+	variable := c.lookup(name.lexeme)
+	if variable == nil {
+		return
+	}
+	if variable.declared != nil {
+		c.expect(name, variable.declared, value, "'"+name.lexeme+"'")
+	} else if variable.function != nil || variable.class != nil || isAny(value) ||
+		!c.assignable(value, variable.inferred) {
+		c.widened[variable.name] = true
+		variable.inferred, variable.function, variable.class = nil, nil, nil
+	}
+}
+
+// Checks the decorators and annotations, then the parameters and body in a new scope
+func (c *TypeChecker) checkFunction(function *FunctionStmt) {
+	for _, decorator := range function.decorators {
+		c.typeOf(decorator)
+	}
+	enclosingFunction := c.currentFunction
+	c.currentFunction = function
+	c.checkAnnotation(function.returnType)
+	c.beginScope()
+
+	for _, param := range function.params {
+		c.checkAnnotation(param.annotation)
+		if param.defaultValue != nil {
+			c.expect(param.name, param.annotation, c.typeOf(param.defaultValue),
+				"default value of '"+param.name.lexeme+"'")
+		}
+		inferred := param.annotation
+		if param.isRest && inferred == nil {
+			inferred = namedType("list")
+		}
+		c.declare(&checkVariable{name: param.name, declared: param.annotation, inferred: inferred})
+	}
+	c.checkStatements(function.body)
+
+	c.endScope()
+	c.currentFunction = enclosingFunction
+}
+
+// Check the arguments of a call against the annotated parameters of a function
+func (c *TypeChecker) checkArguments(function *FunctionStmt, call *CallExpr, arguments []*TypeExpr) {
+	for j, argument := range arguments {
+		if _, ok := call.arguments[j].(*SpreadExpr); ok || j >= len(function.params) {
+			return
+		}
+		param := function.params[j]
+		if param.isRest {
+			if param.annotation != nil {
+				for _, rest := range arguments[j:] {
+					c.expect(call.paren, param.annotation.element, rest, "parameter '"+param.name.lexeme+"'")
+				}
+			}
+			return
+		}
+		c.expect(call.paren, param.annotation, argument, "parameter '"+param.name.lexeme+"'")
+	}
+}
+
+// Check the statements in a new scope
+func (c *TypeChecker) visitBlockStmt(stmt *BlockStmt) any {
+	c.beginScope()
+	c.checkStatements(stmt.statements)
+	c.endScope()
+	return nil
+}
+
+// Declares the class, then checks its members with "this" as an instance of it
+func (c *TypeChecker) visitClassStmt(stmt *ClassStmt) any {
+	c.classes[stmt.name.lexeme] = stmt
+	c.declare(&checkVariable{name: stmt.name, inferred: namedType("class"), class: stmt})
+	for _, trait := range stmt.traits {
+		c.typeOf(trait)
+	}
+	for _, field := range stmt.staticFields {
+		c.checkAnnotation(field.annotation)
+		if field.initializer != nil {
+			c.expect(field.name, field.annotation, c.typeOf(field.initializer), "'"+field.name.lexeme+"'")
+		}
+	}
+
+	enclosingClass := c.currentClass
+	c.currentClass = stmt
+	for _, members := range [][]*FunctionStmt{stmt.staticMethods, stmt.methods, stmt.setters} {
+		for _, method := range members {
+			c.checkFunction(method)
+		}
+	}
+	c.currentClass = enclosingClass
+	return nil
+}
+
+// Checks the expression
+func (c *TypeChecker) visitExpressionStmt(stmt *ExpressionStmt) any {
+	c.typeOf(stmt.expression)
+	return nil
+}
+
+// Checks that the iterable can be iterated, then the body in a new scope with the loop variables
+func (c *TypeChecker) visitForInStmt(stmt *ForInStmt) any {
+	iterable := c.typeOf(stmt.iterable)
+	var element *TypeExpr
+	switch {
+	case isPrimitive(iterable) && iterable.name.lexeme == "string":
+		element = namedType("string")
+	case isPrimitive(iterable) && iterable.name.lexeme == "list":
+		element = iterable.element
+	case isPrimitive(iterable):
+		c.error(stmt.name, "Can only iterate over lists, strings, and iterators.")
+	}
+
+	c.beginScope()
+	if stmt.pattern != nil {
+		for _, name := range stmt.pattern.bindings() {
+			c.declare(&checkVariable{name: name})
+		}
+	} else {
+		c.declare(&checkVariable{name: stmt.name, inferred: element})
+	}
+	c.checkStmt(stmt.body)
+	c.endScope()
+	return nil
+}
+
+// Variable that a function is declared as, whose type is unknown if a decorator may replace it with anything
+func functionVariable(stmt *FunctionStmt) *checkVariable {
+	if len(stmt.decorators) > 0 {
+		return &checkVariable{name: stmt.name}
+	}
+	return &checkVariable{name: stmt.name, inferred: namedType("function"), function: stmt}
+}
+
+// Declares and checks the function
+func (c *TypeChecker) visitFunctionStmt(stmt *FunctionStmt) any {
+	c.declare(functionVariable(stmt))
+	c.checkFunction(stmt)
+	return nil
+}
+
+// Checks the condition and branches
+func (c *TypeChecker) visitIfStmt(stmt *IfStmt) any {
+	c.typeOf(stmt.condition)
+	c.checkStmt(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		c.checkStmt(stmt.elseBranch)
+	}
+	return nil
+}
+
+// Checks the return value against the return type of the function
+// Generators return a generator, rather than the returned value
+func (c *TypeChecker) visitReturnStmt(stmt *ReturnStmt) any {
+	value := namedType("nil")
+	if stmt.value != nil {
+		value = c.typeOf(stmt.value)
+	}
+	if function := c.currentFunction; function != nil && !function.isGenerator {
+		c.expect(stmt.keyword, function.returnType, value, "return value of '"+function.name.lexeme+"'")
+	}
+	return nil
+}
+
+// Declares the trait, and checks the default methods
+func (c *TypeChecker) visitTraitStmt(stmt *TraitStmt) any {
+	c.traits[stmt.name.lexeme] = stmt
+	c.declare(&checkVariable{name: stmt.name, inferred: namedType("trait")})
+
+	enclosingClass := c.currentClass
+	c.currentClass = nil
+	for _, method := range stmt.required {
+		c.checkAnnotation(method.returnType)
+		for _, param := range method.params {
+			c.checkAnnotation(param.annotation)
+		}
+	}
+	for _, method := range stmt.methods {
+		c.checkFunction(method)
+	}
+	c.currentClass = enclosingClass
+	return nil
+}
+
+// Checks the value against the annotation, then declares the variable with the type it holds
+func (c *TypeChecker) visitVarStmt(stmt *VarStmt) any {
+	c.checkAnnotation(stmt.annotation)
+	var value *TypeExpr
+	if stmt.initializer != nil {
+		value = c.typeOf(stmt.initializer)
+		c.expect(stmt.name, stmt.annotation, value, "'"+stmt.name.lexeme+"'")
+	}
+
+	if stmt.pattern != nil {
+		for _, name := range stmt.pattern.bindings() {
+			c.declare(&checkVariable{name: name})
+		}
+		return nil
+	}
+	inferred := stmt.annotation
+	if inferred == nil {
+		inferred = value
+	}
+	c.declare(&checkVariable{name: stmt.name, declared: stmt.annotation, inferred: inferred})
+	return nil
+}
+
+// Checks the condition and the body
+func (c *TypeChecker) visitWhileStmt(stmt *WhileStmt) any {
+	c.typeOf(stmt.condition)
+	c.checkStmt(stmt.body)
+	return nil
+}
+
+// Checks the value against the variable, and gives the type of the value
+func (c *TypeChecker) visitAssignExpr(expr *AssignExpr) any {
+	value := c.typeOf(expr.value)
+	c.assign(expr.name, value)
+	return value
+}
+
+// Checks that the operands can be used with the operator, and gives the type of the result
+func (c *TypeChecker) visitBinaryExpr(expr *BinaryExpr) any {
+	left, right := c.typeOf(expr.left), c.typeOf(expr.right)
+	operator := expr.operator.tokenType
+
+	switch operator {
+	case EQUAL_EQUAL, BANG_EQUAL:
+		return namedType("bool")
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		if (isPrimitive(left) && !isNumberType(left)) || (isPrimitive(right) && !isNumberType(right)) {
+			c.error(expr.operator, "Operands must be numbers.")
+		}
+		return namedType("bool")
+	}
+
+	if !isPrimitive(left) || !isPrimitive(right) {
+		return nil
+	}
+	if isNumberType(left) && isNumberType(right) {
+		switch {
+		case operator == SLASH || operator == SLASH_EQUAL:
+			return namedType("float")
+		case left.name.lexeme == "float" || right.name.lexeme == "float":
+			return namedType("float")
+		case left.name.lexeme == "int" && right.name.lexeme == "int":
+			return namedType("int")
+		}
+		return namedType("number")
+	}
+
+	switch operator {
+	case PLUS, PLUS_EQUAL, PLUS_PLUS:
+		if (left.name.lexeme == "string" || left.name.lexeme == "list") && left.name.lexeme == right.name.lexeme {
+			if left.String() == right.String() {
+				return left
+			}
+			return namedType(left.name.lexeme)
+		}
+		c.error(expr.operator, "Operands must be two numbers, two strings, or two lists.")
+	default:
+		c.error(expr.operator, "Operands must be numbers.")
+	}
+	return nil
+}
+
+// Checks the arguments of calls to known functions and classes, and gives the type of the result
+func (c *TypeChecker) visitCallExpr(expr *CallExpr) any {
+	callee := c.typeOf(expr.callee)
+	arguments := []*TypeExpr{}
+	for _, argument := range expr.arguments {
+		arguments = append(arguments, c.typeOf(argument))
+	}
+	keywords := map[string]*TypeExpr{}
+	for _, keyword := range expr.keywords {
+		keywords[keyword.name.lexeme] = c.typeOf(keyword.value)
+	}
+
+	var function *FunctionStmt
+	var result *TypeExpr
+	if variable, ok := expr.callee.(*VariableExpr); ok {
+		if known := c.lookup(variable.lexeme); known != nil && known.function != nil {
+			function, result = known.function, known.function.returnType
+			if function.isGenerator {
+				result = nil
+			}
+		} else if known != nil && known.class != nil {
+			result = namedType(known.class.name.lexeme)
+			for _, method := range known.class.methods {
+				if method.name.lexeme == "init" {
+					function = method
+				}
+			}
+		} else if known == nil {
+			if name, ok := nativeReturnTypes[variable.lexeme]; ok {
+				result = namedType(name)
+			}
+		}
+	} else if isPrimitive(callee) && callee.name.lexeme != "function" && callee.name.lexeme != "class" {
+		c.error(expr.paren, "Can only call functions and classes.")
+	}
+
+	if function != nil {
+		c.checkArguments(function, expr, arguments)
+		for _, param := range function.params {
+			if value, ok := keywords[param.name.lexeme]; ok {
+				c.expect(expr.paren, param.annotation, value, "parameter '"+param.name.lexeme+"'")
+			}
+		}
+	}
+	return result
+}
+
+// Checks the chain, which may short-circuit to nil
+func (c *TypeChecker) visitChainExpr(expr *ChainExpr) any {
+	c.typeOf(expr.expression)
+	return nil
+}
+
+// Checks the value, then notes each variable target as assigned
+func (c *TypeChecker) visitDestructureExpr(expr *DestructureExpr) any {
+	value := c.typeOf(expr.value)
+	c.checkTargets(expr.targets)
+	return value
+}
+
+// Checks destructuring targets, whose values are not known
+func (c *TypeChecker) checkTargets(targets []Expr) {
+	for _, target := range targets {
+		if spread, ok := target.(*SpreadExpr); ok {
+			target = spread.expression
+		}
+
+		switch target := target.(type) {
+		case *VariableExpr:
+			c.assign(target.Token, nil)
+		case *GetExpr:
+			c.typeOf(target.object)
+		case *IndexExpr:
+			c.typeOf(target.indexee)
+			c.typeOf(target.start)
+		case *ListExpr:
+			c.checkTargets(target.elements)
+		}
+	}
+}
+
+// Checks the object, whose properties are not known
+func (c *TypeChecker) visitGetExpr(expr *GetExpr) any {
+	c.typeOf(expr.object)
+	return nil
+}
+
+// Gives the type of the expression
+func (c *TypeChecker) visitGroupingExpr(expr *GroupingExpr) any {
+	return c.typeOf(expr.expression)
+}
+
+// Checks that the indexee can be indexed, and gives the type of the element or slice
+func (c *TypeChecker) visitIndexExpr(expr *IndexExpr) any {
+	indexee := c.typeOf(expr.indexee)
+	c.typeOf(expr.start)
+	if expr.stop != nil {
+		c.typeOf(expr.stop)
+	}
+
+	if !isPrimitive(indexee) {
+		return nil
+	}
+	switch {
+	case indexee.name.lexeme == "string":
+		return indexee
+	case indexee.name.lexeme == "list" && expr.stop != nil:
+		return indexee
+	case indexee.name.lexeme == "list":
+		return indexee.element
+	}
+	c.error(expr.bracket, "Can only index strings and lists.")
+	return nil
+}
+
+// Gives a list of the type of the elements, if they all have the same type
+func (c *TypeChecker) visitListExpr(expr *ListExpr) any {
+	var element *TypeExpr
+	same := true
+	for j, item := range expr.elements {
+		itemType := c.typeOf(item)
+		if _, ok := item.(*SpreadExpr); ok {
+			same = false
+		} else if j == 0 {
+			element = itemType
+		} else if element.String() != itemType.String() {
+			same = false
+		}
+	}
+	if !same || isAny(element) {
+		element = nil
+	}
+	return &TypeExpr{name: namedType("list").name, element: element}
+}
+
+// Gives the type of the literal
+func (*TypeChecker) visitLiteralExpr(expr *LiteralExpr) any {
+	return namedType(valueType(expr.value))
+}
+
+// Checks both operands, and gives their type if they have the same one
+func (c *TypeChecker) visitLogicalExpr(expr *LogicalExpr) any {
+	left, right := c.typeOf(expr.left), c.typeOf(expr.right)
+	if expr.operator.tokenType == QUESTION_QUESTION && left.nullable {
+		left = &TypeExpr{name: left.name, element: left.element}
+	}
+	if left.String() == right.String() {
+		return right
+	}
+	return nil
+}
+
+// Checks the subject, then each arm in its own scope with its bindings
+func (c *TypeChecker) visitMatchExpr(expr *MatchExpr) any {
+	c.typeOf(expr.subject)
+
+	for _, arm := range expr.arms {
+		c.beginScope()
+		for _, name := range arm.bindings {
+			c.declare(&checkVariable{name: name})
+		}
+		for _, pattern := range arm.patterns {
+			c.checkPattern(pattern)
+		}
+
+		if arm.guard != nil {
+			c.typeOf(arm.guard)
+		}
+		if arm.body != nil {
+			c.checkStmt(arm.body)
+		} else {
+			c.typeOf(arm.value)
+		}
+		c.endScope()
+	}
+	return nil
+}
+
+// Checks any expressions within a pattern (only the classes of class patterns)
+func (c *TypeChecker) checkPattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *ListPattern:
+		for _, element := range pattern.elements {
+			c.checkPattern(element)
+		}
+	case *ClassPattern:
+		c.typeOf(pattern.class)
+		for _, arg := range pattern.args {
+			c.checkPattern(arg)
+		}
+	case *ObjectPattern:
+		for _, field := range pattern.fields {
+			c.checkPattern(field.pattern)
+		}
+	}
+}
+
+// Checks that the indexee can be indexed, and gives the type of the value
+func (c *TypeChecker) visitReplaceExpr(expr *ReplaceExpr) any {
+	indexee := c.typeOf(expr.indexee)
+	c.typeOf(expr.index)
+	value := c.typeOf(expr.value)
+	if isPrimitive(indexee) && indexee.name.lexeme != "list" && indexee.name.lexeme != "string" {
+		c.error(expr.bracket, "Can only index strings and lists.")
+	}
+	return value
+}
+
+// Checks the object, and gives the type of the value
+func (c *TypeChecker) visitSetExpr(expr *SetExpr) any {
+	c.typeOf(expr.object)
+	return c.typeOf(expr.value)
+}
+
+// Checks the call, which gives a task
+func (c *TypeChecker) visitSpawnExpr(expr *SpawnExpr) any {
+	c.typeOf(expr.call)
+	return namedType("instance")
+}
+
+// Checks the spread expression
+func (c *TypeChecker) visitSpreadExpr(expr *SpreadExpr) any {
+	c.typeOf(expr.expression)
+	return nil
+}
+
+// Checks the condition and both values, and gives their type if they have the same one
+func (c *TypeChecker) visitTernaryExpr(expr *TernaryExpr) any {
+	c.typeOf(expr.condition)
+	trueValue, falseValue := c.typeOf(expr.trueValue), c.typeOf(expr.falseValue)
+	if trueValue.String() == falseValue.String() {
+		return trueValue
+	}
+	return nil
+}
+
+// Gives an instance of the enclosing class
+func (c *TypeChecker) visitThisExpr(*ThisExpr) any {
+	if c.currentClass != nil {
+		return namedType(c.currentClass.name.lexeme)
+	}
+	return nil
+}
+
+// Checks that the operand can be used with the operator, and gives the type of the result
+func (c *TypeChecker) visitUnaryExpr(expr *UnaryExpr) any {
+	operand := c.typeOf(expr.operand)
+	switch expr.operator.tokenType {
+	case BANG:
+		return namedType("bool")
+	case MINUS, PLUS:
+		if isNumberType(operand) {
+			return operand
+		} else if isPrimitive(operand) {
+			c.error(expr.operator, "Operand must be a number.")
+		}
+	}
+	return nil
+}
+
+// Gives the type of what the variable holds
+func (c *TypeChecker) visitVariableExpr(expr *VariableExpr) any {
+	if variable := c.lookup(expr.lexeme); variable != nil {
+		return variable.inferred
+	}
+	return nil
+}
+
+// Checks the value, and gives the value sent back to the generator, which is not known
+func (c *TypeChecker) visitYieldExpr(expr *YieldExpr) any {
+	if expr.value != nil {
+		c.typeOf(expr.value)
+	}
+	return nil
+}
@@ -1,10 +1,16 @@
 // Ward Jaeger, CS 403
 package main
 
+import "sync"
+
 // A bundle of data with methods that operate on it, created by an initizalizer
 type Class struct {
-	name    string
-	methods map[string]*Function
+	name        string
+	declaration *ClassStmt           // nil for classes built by natives
+	methods     map[string]*Function // Includes getters
+	setters     map[string]*Function
+	statics     map[string]any // Static fields and methods, which belong to the class itself
+	staticLock  sync.RWMutex
 }
 
 // Test for interface implementation
@@ -14,19 +20,19 @@ func (c *Class) toString() string {
 	return c.name
 }
 
-func (c *Class) arity() int {
+func (c *Class) arity() (int, int) {
 	// Return arity of initializer if it exists, or 0 otherwise
 	if initializer := c.findMethod("init"); initializer != nil {
 		return initializer.arity()
 	}
-	return 0
+	return 0, 0
 }
 
 func (c *Class) call(interpreter *Interpreter, arguments []any) any {
 	// Create a new instance of this class, and initialize it
 	instance := &Instance{Class: c, fields: map[string]any{}}
 	if initializer := c.findMethod("init"); initializer != nil {
-		initializer.bind(instance).call(interpreter, arguments)
+		instance.bindMethod(interpreter, initializer).call(interpreter, arguments)
 	}
 
 	return instance
@@ -40,3 +46,29 @@ func (c *Class) findMethod(name string) *Function {
 
 	return nil
 }
+
+// Get static field or method, calling it if it is a getter
+func (c *Class) getStatic(interpreter *Interpreter, name Token) any {
+	c.staticLock.RLock()
+	static, found := c.statics[name.lexeme]
+	c.staticLock.RUnlock()
+
+	if function, ok := static.(*Function); ok && function.declaration.isGetter {
+		return function.call(interpreter, []any{})
+	} else if found {
+		return static
+	}
+
+	panic(RuntimeError{token: name,
+		message: "Undefined static property '" + name.lexeme + "'."})
+}
+
+// Set static field
+func (c *Class) setStatic(name Token, value any) {
+	c.staticLock.Lock()
+	defer c.staticLock.Unlock()
+	if c.statics == nil {
+		c.statics = map[string]any{}
+	}
+	c.statics[name.lexeme] = value
+}
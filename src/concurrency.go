@@ -0,0 +1,240 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The outcome of a spawned call, which is available once done is closed
+type task struct {
+	done   chan struct{}
+	result any
+	err    any   // Anything panicked by the call, to be panicked again when joined
+	joined int32 // Set once the task has been joined, so its error has been seen
+}
+
+// Tasks whose calls ended with an error, so that the ones never joined can be reported when the run ends
+type taskFailures struct {
+	lock  sync.Mutex
+	tasks []*task
+}
+
+// Start a call on its own goroutine, returning a task instance that can be joined for the result
+func newTask(i *Interpreter, callable Callable, arguments []any, paren Token) *Instance {
+	t := &task{done: make(chan struct{})}
+
+	// The call runs with its own copy of the interpreter, so its environment stays separate
+	i.sandbox.startTask(paren)
+	taskInterpreter := i.fork(i.environment)
+	go func() {
+		defer func() {
+			taskInterpreter.sandbox.endTask()
+			t.err = recover()
+			// A limit applies to the whole run, so it ends the run even if the task is never joined
+			if err, ok := t.err.(LimitError); ok {
+				taskInterpreter.sandbox.fail(err)
+			} else if t.err != nil {
+				i.failedTasks.add(t)
+			}
+			close(t.done)
+		}()
+		t.result = taskInterpreter.callWithToken(callable, arguments, paren)
+	}()
+
+	instance := newNativeInstance("Task", map[string]any{
+		"join": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(i *Interpreter, _ []any) any {
+				return t.join(i)
+			},
+		},
+		"done": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				select {
+				case <-t.done:
+					return true
+				default:
+					return false
+				}
+			},
+		},
+	})
+	instance.native = t
+	return instance
+}
+
+// Wait for the call to finish, then return its result or throw its error
+func (t *task) join(i *Interpreter) any {
+	select {
+	case <-t.done:
+	case <-i.sandbox.cancelled():
+		i.sandbox.interrupted()
+	}
+	atomic.StoreInt32(&t.joined, 1)
+	if t.err != nil {
+		panic(t.err)
+	}
+	return t.result
+}
+
+// Record a task that ended with an error
+func (f *taskFailures) add(t *task) {
+	f.lock.Lock()
+	f.tasks = append(f.tasks, t)
+	f.lock.Unlock()
+}
+
+// Report the errors of failed tasks that were never joined, since nothing else would
+// The failures are cleared, so that each is reported once
+func (f *taskFailures) reportUnjoined() {
+	f.lock.Lock()
+	tasks := f.tasks
+	f.tasks = nil
+	f.lock.Unlock()
+
+	for _, t := range tasks {
+		if atomic.LoadInt32(&t.joined) == 1 {
+			continue
+		}
+		if err, ok := t.err.(RuntimeError); ok {
+			reportRuntime(err)
+		} else {
+			panic(t.err)
+		}
+	}
+}
+
+// Build a channel, a native instance that passes values between tasks
+// Its next method makes it usable as an iterator, returning nil once the channel is closed and empty
+func newChannel(capacity int) *Instance {
+	values := make(chan any, capacity)
+
+	recv := &Native{
+		arityFunc: func() (int, int) { return 0, 0 },
+		callFunc: func(i *Interpreter, _ []any) any {
+			select {
+			case value := <-values:
+				return value
+			case <-i.sandbox.cancelled():
+				i.sandbox.interrupted()
+				return nil
+			}
+		},
+	}
+
+	instance := newNativeInstance("Channel", map[string]any{
+		"send": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(i *Interpreter, args []any) any {
+				checkSendable(args[0])
+				defer recoverClosedChannel()
+				select {
+				case values <- args[0]:
+				case <-i.sandbox.cancelled():
+					i.sandbox.interrupted()
+				}
+				return nil
+			},
+		},
+		"recv": recv,
+		"next": recv,
+		"close": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				defer recoverClosedChannel()
+				close(values)
+				return nil
+			},
+		},
+	})
+	instance.native = values
+	return instance
+}
+
+// Wait until one of several channel operations can proceed, and perform it
+// Each case is either a channel to receive from, or a list of a channel and a value to send on it
+// Returns a list of the channel and the value received or sent, or nil if the timeout runs out first
+func selectChannels(i *Interpreter, cases []any, timeout any) any {
+	selectCases := []reflect.SelectCase{}
+	channels := []any{}
+	for _, c := range cases {
+		if send, ok := c.(Sequence); ok && !send.isString && send.size() == 2 {
+			value := send.element(1)
+			checkSendable(value)
+			selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectSend,
+				Chan: reflect.ValueOf(expectChannel(send.element(0))), Send: reflect.ValueOf(&value).Elem()})
+			channels = append(channels, send.element(0))
+		} else {
+			selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectRecv,
+				Chan: reflect.ValueOf(expectChannel(c))})
+			channels = append(channels, c)
+		}
+	}
+
+	// A timeout of zero only checks whether some operation can proceed immediately
+	seconds, ok := 0.0, isNumber(timeout)
+	if ok {
+		seconds = toFloat(timeout)
+	}
+	if ok && seconds <= 0 {
+		selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	} else if ok {
+		timer := time.After(time.Duration(seconds * float64(time.Second)))
+		selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectRecv,
+			Chan: reflect.ValueOf(timer)})
+	} else if timeout != nil {
+		panic(RuntimeError{message: "Timeout must be a number."})
+	}
+
+	// Cutting the run short takes priority over the select's timeout
+	selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectRecv,
+		Chan: reflect.ValueOf(i.sandbox.cancelled())})
+
+	defer recoverClosedChannel()
+	chosen, received, ok := reflect.Select(selectCases)
+	if chosen == len(selectCases)-1 {
+		i.sandbox.interrupted()
+	}
+	if chosen >= len(channels) {
+		return nil
+	}
+
+	var value any
+	if selectCases[chosen].Dir == reflect.SelectSend {
+		value = selectCases[chosen].Send.Interface()
+	} else if ok {
+		value = received.Interface()
+	}
+	return Sequence{list: []any{channels[chosen], value}, isString: false}
+}
+
+// Get the Go channel backing a channel instance, or throw an error
+func expectChannel(value any) chan any {
+	if instance, ok := value.(*Instance); ok {
+		if values, ok := instance.native.(chan any); ok {
+			return values
+		}
+	}
+	panic(RuntimeError{message: "Expect channel."})
+}
+
+// Check that a value can be sent, since nil is what a closed channel receives
+func checkSendable(value any) {
+	if value == nil {
+		panic(RuntimeError{message: "Can't send nil on a channel."})
+	}
+}
+
+// Convert a panic from a closed Go channel into a runtime error
+func recoverClosedChannel() {
+	if r := recover(); r != nil {
+		if _, ok := r.(error); ok {
+			panic(RuntimeError{message: "Channel is closed."})
+		}
+		panic(r)
+	}
+}
@@ -0,0 +1,262 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A place where execution splits in two, like the arms of an if statement
+// Arm 0 is the then branch or the short-circuit, and arm 1 is the else branch or the right side
+type branchPoint struct {
+	line  int
+	block int // Numbered in the order the resolver finds them, so they match across runs
+	taken [2]int64
+}
+
+// Records which lines and branches of a script are executed
+type coverage struct {
+	lock     sync.Mutex
+	lines    map[int]int64        // Execution count of each line with a statement
+	branches map[any]*branchPoint // Keyed by the statement or expression that branches
+	order    []*branchPoint       // Branch points in the order they were found
+}
+
+// Build an empty record of coverage
+func newCoverage() *coverage {
+	return &coverage{lines: map[int]int64{}, branches: map[any]*branchPoint{}}
+}
+
+// Note a line that has a statement, which is reported even if it is never executed
+func (c *coverage) addLine(line int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.lines[line]; !ok {
+		c.lines[line] = 0
+	}
+}
+
+// Note a statement or expression that branches, which is reported even if it is never executed
+func (c *coverage) addBranch(node any, line int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.branches[node]; !ok {
+		point := &branchPoint{line: line, block: len(c.order)}
+		c.branches[node] = point
+		c.order = append(c.order, point)
+	}
+}
+
+// Count an execution of a line
+func (c *coverage) hitLine(line int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lines[line]++
+}
+
+// Count an arm of a branch being taken
+func (c *coverage) takeBranch(node any, arm int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if point, ok := c.branches[node]; ok {
+		point.taken[arm]++
+	}
+}
+
+// Coverage of one source file, as it is stored in an lcov file
+type coverageRecord struct {
+	source   string
+	lines    map[int]int64
+	branches map[[3]int]int64 // Keyed by line, block, and arm
+}
+
+// Write the coverage of a script to an lcov file, adding it to the coverage already in the file,
+// then write an annotated copy of each source file in the lcov file next to it with the extension .txt
+func (c *coverage) write(path string, source string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	records, err := readLcov(path)
+	if err != nil {
+		return err
+	}
+	record, ok := records[source]
+	if !ok {
+		record = &coverageRecord{source: source, lines: map[int]int64{}, branches: map[[3]int]int64{}}
+		records[source] = record
+	}
+	for line, count := range c.lines {
+		record.lines[line] += count
+	}
+	for _, point := range c.order {
+		for arm, count := range point.taken {
+			record.branches[[3]int{point.line, point.block, arm}] += count
+		}
+	}
+
+	sources := []string{}
+	for source := range records {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var lcov, report strings.Builder
+	for _, source := range sources {
+		records[source].writeLcov(&lcov)
+		records[source].writeReport(&report)
+	}
+	if err := os.WriteFile(path, []byte(lcov.String()), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(coverageReportPath(path), []byte(report.String()), 0644)
+}
+
+// Path of the annotated source for a given lcov path
+func coverageReportPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".txt"
+}
+
+// Read the records of an lcov file, which is empty if the file does not exist yet
+func readLcov(path string) (map[string]*coverageRecord, error) {
+	records := map[string]*coverageRecord{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var record *coverageRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), ":")
+		fields := strings.Split(value, ",")
+		switch {
+		case key == "SF":
+			record = &coverageRecord{source: value, lines: map[int]int64{}, branches: map[[3]int]int64{}}
+			records[value] = record
+		case key == "DA" && record != nil && len(fields) >= 2:
+			line, _ := strconv.Atoi(fields[0])
+			count, _ := strconv.ParseInt(fields[1], 10, 64)
+			record.lines[line] += count
+		case key == "BRDA" && record != nil && len(fields) == 4:
+			line, _ := strconv.Atoi(fields[0])
+			block, _ := strconv.Atoi(fields[1])
+			arm, _ := strconv.Atoi(fields[2])
+			// A "-" means the branch point was never reached, which counts as zero
+			count, _ := strconv.ParseInt(fields[3], 10, 64)
+			record.branches[[3]int{line, block, arm}] += count
+		}
+	}
+	return records, scanner.Err()
+}
+
+// Sorted keys of the branches of a record
+func (r *coverageRecord) branchKeys() [][3]int {
+	keys := [][3]int{}
+	for key := range r.branches {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		for j := range keys[a] {
+			if keys[a][j] != keys[b][j] {
+				return keys[a][j] < keys[b][j]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+// Sorted lines of a record
+func (r *coverageRecord) lineNumbers() []int {
+	lines := []int{}
+	for line := range r.lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Count the lines and branches of a record that were executed at least once
+func (r *coverageRecord) summary() (linesHit int, branchesHit int) {
+	for _, count := range r.lines {
+		if count > 0 {
+			linesHit++
+		}
+	}
+	for _, count := range r.branches {
+		if count > 0 {
+			branchesHit++
+		}
+	}
+	return linesHit, branchesHit
+}
+
+// Write a record in the lcov format
+func (r *coverageRecord) writeLcov(builder *strings.Builder) {
+	builder.WriteString("TN:\nSF:" + r.source + "\n")
+	for _, line := range r.lineNumbers() {
+		builder.WriteString(fmt.Sprintf("DA:%d,%d\n", line, r.lines[line]))
+	}
+	for _, key := range r.branchKeys() {
+		taken := strconv.FormatInt(r.branches[key], 10)
+		if r.branches[[3]int{key[0], key[1], 0}]+r.branches[[3]int{key[0], key[1], 1}] == 0 {
+			taken = "-"
+		}
+		builder.WriteString(fmt.Sprintf("BRDA:%d,%d,%d,%s\n", key[0], key[1], key[2], taken))
+	}
+	linesHit, branchesHit := r.summary()
+	builder.WriteString(fmt.Sprintf("BRF:%d\nBRH:%d\n", len(r.branches), branchesHit))
+	builder.WriteString(fmt.Sprintf("LF:%d\nLH:%d\nend_of_record\n", len(r.lines), linesHit))
+}
+
+// Write a copy of the source file annotated with the execution count of each line,
+// followed by the arms of any branches on the line that were never taken
+func (r *coverageRecord) writeReport(builder *strings.Builder) {
+	linesHit, branchesHit := r.summary()
+	builder.WriteString(fmt.Sprintf("%s: %s of lines (%d/%d), %s of branches (%d/%d)\n\n", r.source,
+		percent(linesHit, len(r.lines)), linesHit, len(r.lines),
+		percent(branchesHit, len(r.branches)), branchesHit, len(r.branches)))
+
+	contents, err := os.ReadFile(r.source)
+	if err != nil {
+		builder.WriteString("Could not open file " + r.source + "\n\n")
+		return
+	}
+	missed := map[int][]string{}
+	for _, key := range r.branchKeys() {
+		if r.branches[key] == 0 {
+			missed[key[0]] = append(missed[key[0]], fmt.Sprintf("branch %d.%d never taken", key[1], key[2]))
+		}
+	}
+
+	for j, text := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+		line, count := j+1, "     "
+		if executions, ok := r.lines[line]; ok && executions == 0 {
+			count = "#####"
+		} else if ok {
+			count = fmt.Sprintf("%5d", executions)
+		}
+		builder.WriteString(fmt.Sprintf("%s %5d | %s\n", count, line, text))
+		for _, note := range missed[line] {
+			builder.WriteString(fmt.Sprintf("%5s %5s | %s\n", "!", "", note))
+		}
+	}
+	builder.WriteString("\n")
+}
+
+// Format a fraction as a percentage, where nothing out of nothing is complete
+func percent(numerator int, denominator int) string {
+	if denominator == 0 {
+		return "100.0%"
+	}
+	return strconv.FormatFloat(100*float64(numerator)/float64(denominator), 'f', 1, 64) + "%"
+}
@@ -0,0 +1,248 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Callable returned by a built-in decorator, which takes the same arguments as the callable it wraps
+type Decorated struct {
+	wrapped  Callable
+	callFunc func(interpreter *Interpreter, arguments []any) any
+}
+
+// Test for interface implementation
+var _ Callable = &Decorated{}
+
+func (d *Decorated) toString() string {
+	return d.wrapped.toString()
+}
+
+func (d *Decorated) arity() (int, int) {
+	return d.wrapped.arity()
+}
+
+func (d *Decorated) call(interpreter *Interpreter, arguments []any) any {
+	return d.callFunc(interpreter, arguments)
+}
+
+// Log for the trace and timed decorators, which always write to standard error
+var decoratorLog = &tracer{output: os.Stderr}
+
+// Build the built-in decorators, keyed by their global names
+func decoratorNatives() map[string]any {
+	return map[string]any{
+		"memoize": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return memoize(expectCallable(args[0]))
+			},
+		},
+		"trace": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return traced(expectCallable(args[0]))
+			},
+		},
+		"timed": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return timed(expectCallable(args[0]))
+			},
+		},
+	}
+}
+
+// Get a callable, or throw an error
+func expectCallable(value any) Callable {
+	if callable, ok := value.(Callable); ok {
+		return callable
+	}
+	panic(RuntimeError{message: "Expect function or class."})
+}
+
+// Name of a callable, as it was declared
+func callableName(callable Callable) string {
+	switch callable := callable.(type) {
+	case *Function:
+		return callable.declaration.name.lexeme
+	case *Native:
+		return callable.name
+	case *Class:
+		return callable.name
+	case *Decorated:
+		return callableName(callable.wrapped)
+	}
+	return callable.toString()
+}
+
+// Apply the decorators of a function to it
+func (i *Interpreter) decorate(function *Function) any {
+	return i.applyDecorators(function, i.evaluateDecorators(function.declaration), function.declaration.name)
+}
+
+// Evaluate the decorators of a function, in the order they are written
+func (i *Interpreter) evaluateDecorators(declaration *FunctionStmt) []Callable {
+	decorators := []Callable{}
+	for _, expr := range declaration.decorators {
+		decorator, ok := i.evaluate(expr).(Callable)
+		if !ok {
+			panic(RuntimeError{token: declaration.name,
+				message: "Can only decorate with functions and classes."})
+		}
+		decorators = append(decorators, decorator)
+	}
+	return decorators
+}
+
+// Pass a value through decorators, from the one written last to the one written first
+func (i *Interpreter) applyDecorators(value any, decorators []Callable, name Token) any {
+	for j := len(decorators) - 1; j >= 0; j-- {
+		arguments := bindArguments(decorators[j], []any{value}, map[string]any{}, name)
+		value = i.callWithToken(decorators[j], arguments, name)
+	}
+	return value
+}
+
+// A call that has already been made by a memoized callable
+type memoEntry struct {
+	arguments []any
+	value     any
+}
+
+// Wrap a callable so that it is only called once for each list of arguments, which are compared like "=="
+func memoize(wrapped Callable) *Decorated {
+	cache := map[string][]memoEntry{}
+	var lock sync.Mutex
+
+	return &Decorated{wrapped: wrapped, callFunc: func(interpreter *Interpreter, arguments []any) any {
+		// Calls that leave out an argument share entries with calls that give its default
+		arguments = withDefaults(interpreter, wrapped, arguments)
+
+		// Only arguments with the same key can be equal, so only those are compared
+		key := memoKey(arguments)
+		lock.Lock()
+		entries := cache[key]
+		lock.Unlock()
+		for _, entry := range entries {
+			if interpreter.compare(Sequence{list: entry.arguments}, Sequence{list: arguments}, interpreter.nativeCall) {
+				return entry.value
+			}
+		}
+
+		// The arguments are copied, so that changing a list afterward doesn't change what was cached
+		snapshot := copyLists(arguments)
+		value := wrapped.call(interpreter, arguments)
+		lock.Lock()
+		cache[key] = append(cache[key], memoEntry{arguments: snapshot, value: value})
+		lock.Unlock()
+		return value
+	}}
+}
+
+// Key that is the same for any two values that can be equal
+// Instances may overload equality with anything, so every list of arguments with an instance shares a key
+func memoKey(value any) string {
+	switch value := value.(type) {
+	case nil, bool:
+		return fmt.Sprint(value)
+	case int64, *big.Int:
+		return toBig(value).String()
+	case float64:
+		// Floats with integer values are equal to integers
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			integer, _ := new(big.Float).SetFloat64(value).Int(nil)
+			return integer.String()
+		}
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case Sequence:
+		if value.isString {
+			return strconv.Quote(value.toGoString())
+		}
+		keys := []string{}
+		for _, element := range value.elements() {
+			key := memoKey(element)
+			if key == "instance" {
+				return key
+			}
+			keys = append(keys, key)
+		}
+		return "[" + strings.Join(keys, ", ") + "]"
+	case []any:
+		return memoKey(Sequence{list: value})
+	case *Instance:
+		return "instance"
+	case missingArgument:
+		return "missing"
+	}
+	return fmt.Sprintf("%p", value)
+}
+
+// Fill in the defaults for the arguments left out of a call, if the callable runs a WIXME function
+func withDefaults(interpreter *Interpreter, callable Callable, arguments []any) []any {
+	switch callable := callable.(type) {
+	case *Function:
+		return callable.withDefaults(interpreter, arguments)
+	case *Decorated:
+		return withDefaults(interpreter, callable.wrapped, arguments)
+	}
+	return arguments
+}
+
+// Copy a list of values, along with any lists nested inside them
+func copyLists(values []any) []any {
+	copied := make([]any, len(values))
+	for j, value := range values {
+		if sequence, ok := value.(Sequence); ok && !sequence.isString {
+			value = Sequence{list: copyLists(sequence.elements()), isString: false}
+		}
+		copied[j] = value
+	}
+	return copied
+}
+
+// Wrap a callable so that each call to it is logged with its arguments, and with its return value or error
+func traced(wrapped Callable) *Decorated {
+	name := callableName(wrapped)
+	return &Decorated{wrapped: wrapped, callFunc: func(interpreter *Interpreter, arguments []any) (returnValue any) {
+		args := []string{}
+		for _, argument := range arguments {
+			if !isMissing(argument) {
+				args = append(args, interpreter.stringify(argument, true, interpreter.nativeCall))
+			}
+		}
+		depth := interpreter.tracedDepth
+		decoratorLog.log(depth, "-> "+name+"("+strings.Join(args, ", ")+")")
+
+		interpreter.tracedDepth++
+		defer func() {
+			interpreter.tracedDepth = depth
+			if r := recover(); r != nil {
+				if err, ok := r.(RuntimeError); ok {
+					decoratorLog.log(depth, "<- "+name+" threw \""+err.message+"\"")
+				}
+				panic(r)
+			}
+			decoratorLog.log(depth, "<- "+name+" = "+interpreter.stringify(returnValue, true, interpreter.nativeCall))
+		}()
+		return wrapped.call(interpreter, arguments)
+	}}
+}
+
+// Wrap a callable so that the time each call to it takes is logged
+func timed(wrapped Callable) *Decorated {
+	name := callableName(wrapped)
+	return &Decorated{wrapped: wrapped, callFunc: func(interpreter *Interpreter, arguments []any) any {
+		start := time.Now()
+		value := wrapped.call(interpreter, arguments)
+		decoratorLog.log(0, name+" took "+strconv.FormatFloat(time.Since(start).Seconds(), 'g', -1, 64)+" sec")
+		return value
+	}}
+}
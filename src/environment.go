@@ -1,10 +1,14 @@
 // Ward Jaeger, CS 403
 package main
 
+import "sync"
+
 // A location of variable storage, potentially enclosed in a parent environmnet
+// Tasks can share environments through closures, so the values are guarded by a lock
 type Environment struct {
 	enclosing *Environment
 	values    map[string]any
+	lock      sync.RWMutex
 }
 
 // Get parent environment at a certain distance
@@ -18,27 +22,35 @@ func (e *Environment) ancestor(distance int) *Environment {
 
 // Assign value to the current scope of variable name
 func (e *Environment) assign(name Token, value any) {
+	e.lock.Lock()
 	if _, prs := e.values[name.lexeme]; prs {
 		e.values[name.lexeme] = value
+		e.lock.Unlock()
 		return
 	}
+	e.lock.Unlock()
 
 	panic(RuntimeError{token: name, message: "Undefined variable '" + name.lexeme + "'."})
 }
 
 // Assign value to parent scope at certain distance
 func (e *Environment) assignAt(distance int, name Token, value any) {
-	e.ancestor(distance).values[name.lexeme] = value
+	e.ancestor(distance).define(name.lexeme, value)
 }
 
 // Define a new variable in this scope with a given initial value
 func (e *Environment) define(name string, value any) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	e.values[name] = value
 }
 
 // Get value from most recent scope of variable name
 func (e *Environment) get(name Token) any {
-	if value, prs := e.values[name.lexeme]; prs {
+	e.lock.RLock()
+	value, prs := e.values[name.lexeme]
+	e.lock.RUnlock()
+	if prs {
 		return value
 	}
 
@@ -51,5 +63,8 @@ func (e *Environment) get(name Token) any {
 
 // Get value of parent scope at certain distance
 func (e *Environment) getAt(distance int, name string) any {
-	return e.ancestor(distance).values[name]
+	environment := e.ancestor(distance)
+	environment.lock.RLock()
+	defer environment.lock.RUnlock()
+	return environment.values[name]
 }
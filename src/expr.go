@@ -11,18 +11,24 @@ type ExprVisitor interface {
 	visitAssignExpr(*AssignExpr) any
 	visitBinaryExpr(*BinaryExpr) any
 	visitCallExpr(*CallExpr) any
+	visitChainExpr(*ChainExpr) any
+	visitDestructureExpr(*DestructureExpr) any
 	visitGetExpr(*GetExpr) any
 	visitIndexExpr(*IndexExpr) any
 	visitGroupingExpr(*GroupingExpr) any
 	visitListExpr(*ListExpr) any
 	visitLiteralExpr(*LiteralExpr) any
 	visitLogicalExpr(*LogicalExpr) any
+	visitMatchExpr(*MatchExpr) any
 	visitReplaceExpr(*ReplaceExpr) any
 	visitSetExpr(*SetExpr) any
+	visitSpawnExpr(*SpawnExpr) any
+	visitSpreadExpr(*SpreadExpr) any
 	visitTernaryExpr(*TernaryExpr) any
 	visitThisExpr(*ThisExpr) any
 	visitUnaryExpr(*UnaryExpr) any
 	visitVariableExpr(*VariableExpr) any
+	visitYieldExpr(*YieldExpr) any
 }
 
 // Assign an existing variable to a new value
@@ -50,17 +56,47 @@ func (b *BinaryExpr) accept(visitor ExprVisitor) any {
 type CallExpr struct {
 	callee    Expr
 	arguments []Expr
+	keywords  []Keyword
 	paren     Token
+	optional  bool // Whether a nil callee short-circuits the chain
+}
+
+// A keyword argument of a call
+type Keyword struct {
+	name  Token
+	value Expr
 }
 
 func (c *CallExpr) accept(visitor ExprVisitor) any {
 	return visitor.visitCallExpr(c)
 }
 
+// A chain of property accesses, calls, and indices containing optional links
+// If an optional link is applied to nil, the entire chain evaluates to nil
+type ChainExpr struct {
+	expression Expr
+}
+
+func (c *ChainExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitChainExpr(c)
+}
+
+// Assign the elements of a list to several targets
+type DestructureExpr struct {
+	targets []Expr // Variables, properties, indices, nested lists, or a final spread
+	equals  Token
+	value   Expr
+}
+
+func (d *DestructureExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitDestructureExpr(d)
+}
+
 // Get value of instance property
 type GetExpr struct {
-	object Expr
-	name   Token
+	object   Expr
+	name     Token
+	optional bool // Whether a nil object short-circuits the chain
 }
 
 func (g *GetExpr) accept(visitor ExprVisitor) any {
@@ -78,10 +114,11 @@ func (g *GroupingExpr) accept(visitor ExprVisitor) any {
 
 // Get index or slice copy of a Sequence
 type IndexExpr struct {
-	indexee Expr
-	start   Expr
-	stop    Expr
-	bracket Token
+	indexee  Expr
+	start    Expr
+	stop     Expr
+	bracket  Token
+	optional bool // Whether a nil indexee short-circuits the chain
 }
 
 func (c *IndexExpr) accept(visitor ExprVisitor) any {
@@ -100,7 +137,9 @@ func (l *ListExpr) accept(visitor ExprVisitor) any {
 
 // A literal value that needs no additional evaluation
 type LiteralExpr struct {
-	value any
+	value  any
+	token  Token // Where the literal is written, or a related token if the parser or optimizer made it
+	folded bool  // Whether the optimizer made it, so a string must be copied as the operation would have made a new one
 }
 
 func (l *LiteralExpr) accept(visitor ExprVisitor) any {
@@ -118,6 +157,26 @@ func (l *LogicalExpr) accept(visitor ExprVisitor) any {
 	return visitor.visitLogicalExpr(l)
 }
 
+// Evaluate the first arm whose pattern matches the subject
+type MatchExpr struct {
+	keyword Token
+	subject Expr
+	arms    []MatchArm
+}
+
+func (m *MatchExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitMatchExpr(m)
+}
+
+// A single arm of a match expression
+type MatchArm struct {
+	patterns []Pattern // Alternatives, or nil for the default arm
+	bindings []Token   // Variables bound by any of the alternatives
+	guard    Expr      // nil if there is no guard
+	body     Stmt      // Block body, or nil if the arm has an expression body
+	value    Expr      // Expression body, or nil if the arm has a block body
+}
+
 // Replace an element of a Sequence at a given index
 type ReplaceExpr struct {
 	indexee Expr
@@ -141,6 +200,26 @@ func (s *SetExpr) accept(visitor ExprVisitor) any {
 	return visitor.visitSetExpr(s)
 }
 
+// Start a call on its own task
+type SpawnExpr struct {
+	keyword Token
+	call    *CallExpr
+}
+
+func (s *SpawnExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitSpawnExpr(s)
+}
+
+// Expand a list into separate arguments or elements
+type SpreadExpr struct {
+	ellipsis   Token
+	expression Expr
+}
+
+func (s *SpreadExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitSpreadExpr(s)
+}
+
 // If condition is true, return trueValue, otherwise falseValue
 type TernaryExpr struct {
 	condition  Expr
@@ -180,3 +259,13 @@ type VariableExpr struct {
 func (v *VariableExpr) accept(visitor ExprVisitor) any {
 	return visitor.visitVariableExpr(v)
 }
+
+// Suspend a generator, handing a value to whoever resumed it
+type YieldExpr struct {
+	keyword Token
+	value   Expr // nil if no value is yielded
+}
+
+func (y *YieldExpr) accept(visitor ExprVisitor) any {
+	return visitor.visitYieldExpr(y)
+}
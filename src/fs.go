@@ -0,0 +1,191 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Build the fs library, a native instance whose fields are file system natives
+func fsLibrary() *Instance {
+	return newNativeInstance("fs", map[string]any{
+		"readFile": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				contents, err := os.ReadFile(resolvePath(args[0]))
+				checkOSError(err)
+				return newString(string(contents))
+			},
+		},
+		"writeFile": &Native{
+			arityFunc: func() (int, int) { return 2, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				checkOSError(os.WriteFile(resolvePath(args[0]),
+					[]byte(expectString(args[1])), 0644))
+				return nil
+			},
+		},
+		"appendFile": &Native{
+			arityFunc: func() (int, int) { return 2, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				file, err := os.OpenFile(resolvePath(args[0]),
+					os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				checkOSError(err)
+				defer file.Close()
+				_, err = file.WriteString(expectString(args[1]))
+				checkOSError(err)
+				return nil
+			},
+		},
+		"open": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				file, err := os.Open(resolvePath(args[0]))
+				checkOSError(err)
+				return newFileHandle(file)
+			},
+		},
+		"listDir": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				entries, err := os.ReadDir(resolvePath(args[0]))
+				checkOSError(err)
+				names := []string{}
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				sort.Strings(names)
+				return newStringList(names)
+			},
+		},
+		"exists": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				_, err := os.Stat(resolvePath(args[0]))
+				return err == nil
+			},
+		},
+		"isDir": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				info, err := os.Stat(resolvePath(args[0]))
+				return err == nil && info.IsDir()
+			},
+		},
+		"createFile": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				file, err := os.Create(resolvePath(args[0]))
+				checkOSError(err)
+				checkOSError(file.Close())
+				return nil
+			},
+		},
+		"mkdir": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				checkOSError(os.MkdirAll(resolvePath(args[0]), 0755))
+				return nil
+			},
+		},
+		"remove": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				checkOSError(os.Remove(resolvePath(args[0])))
+				return nil
+			},
+		},
+		"removeAll": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				checkOSError(os.RemoveAll(resolvePath(args[0])))
+				return nil
+			},
+		},
+		"join": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, variadic },
+			callFunc: func(_ *Interpreter, args []any) any {
+				parts := []string{}
+				for _, arg := range args {
+					parts = append(parts, expectString(arg))
+				}
+				return newString(filepath.Join(parts...))
+			},
+		},
+		"basename": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return newString(filepath.Base(expectString(args[0])))
+			},
+		},
+		"dirname": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return newString(filepath.Dir(expectString(args[0])))
+			},
+		},
+		"ext": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return newString(filepath.Ext(expectString(args[0])))
+			},
+		},
+	})
+}
+
+// Build a file handle, a native instance that reads an open file line by line
+// Its next method makes it usable as an iterator, returning nil once the file is exhausted
+func newFileHandle(file *os.File) *Instance {
+	reader := newLineReader(file)
+	closed := false
+
+	readLine := &Native{
+		allocates: true,
+		arityFunc: func() (int, int) { return 0, 0 },
+		callFunc: func(interpreter *Interpreter, _ []any) any {
+			if closed {
+				panic(RuntimeError{message: "File is closed."})
+			}
+			return reader.readLine(interpreter.sandbox)
+		},
+	}
+
+	return newNativeInstance("File", map[string]any{
+		"path":     newString(file.Name()),
+		"readLine": readLine,
+		"next":     readLine,
+		"close": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				if !closed {
+					closed = true
+					checkOSError(file.Close())
+				}
+				return nil
+			},
+		},
+	})
+}
+
+// Resolve a WIXME path string, relative to the directory of the running script
+func resolvePath(value any) string {
+	path := expectString(value)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(scriptDir, path)
+}
+
+// Convert an error from the OS into a runtime error, keeping its message
+func checkOSError(err error) {
+	if err != nil {
+		panic(RuntimeError{message: err.Error()})
+	}
+}
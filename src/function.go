@@ -6,11 +6,21 @@ type Function struct {
 	declaration   *FunctionStmt
 	closure       *Environment
 	isInitializer bool
+	decorators    []Callable // Decorators of a method, which are applied to it once for each instance it is bound to
 }
 
 // Test for interface implementation
 var _ Callable = &Function{}
 
+// Placeholder for a parameter that wasn't given an argument, so that its default is used
+type missingArgument struct{}
+
+// Check whether an argument is the placeholder for a missing argument
+func isMissing(argument any) bool {
+	_, ok := argument.(missingArgument)
+	return ok
+}
+
 // Return a new function that is bound to a specific instance
 func (f *Function) bind(instance *Instance) *Function {
 	currEnvironment := &Environment{enclosing: f.closure,
@@ -24,35 +34,150 @@ func (n *Function) toString() string {
 	return "<fn " + n.declaration.name.lexeme + ">"
 }
 
-func (f *Function) arity() int {
-	return len(f.declaration.params)
+func (f *Function) arity() (int, int) {
+	min, max := 0, 0
+	for _, param := range f.declaration.params {
+		if param.isRest {
+			return min, variadic
+		}
+		if param.defaultValue == nil {
+			min++
+		}
+		max++
+	}
+	return min, max
 }
 
 func (f *Function) call(i *Interpreter, arguments []any) (returnValue any) {
-	currEnvironment := &Environment{enclosing: f.closure, values: map[string]any{}}
-	for i, param := range f.declaration.params {
-		currEnvironment.define(param.lexeme, arguments[i])
+	// Trace the call, logging how it ends once the deferred functions below have caught any return
+	if i.tracer != nil {
+		traceEnd := i.traceCall(f, arguments)
+		defer func() {
+			if r := recover(); r != nil {
+				traceEnd(nil, r)
+				panic(r)
+			}
+			traceEnd(returnValue, nil)
+		}()
+	}
+
+	// Tail calls run in place of the function that made them, so the Go stack doesn't grow
+	// Every function in the chain returns the final value, so each one's return type is checked against it
+	returning := []*Function{}
+	for {
+		if i.enforceTypes && f.declaration.returnType != nil && !f.isInitializer && !f.declaration.isGenerator &&
+			!containsDeclaration(returning, f.declaration) {
+			returning = append(returning, f)
+		}
+
+		value, tail := f.run(i, arguments)
+		if tail == nil {
+			for j := len(returning) - 1; j >= 0; j-- {
+				i.enforceReturn(returning[j], value)
+			}
+			return value
+		}
+		f, arguments = tail.function, tail.arguments
+	}
+}
+
+// Check whether one of the functions has a declaration, so a recursive function is only checked once
+func containsDeclaration(functions []*Function, declaration *FunctionStmt) bool {
+	for _, f := range functions {
+		if f.declaration == declaration {
+			return true
+		}
 	}
+	return false
+}
 
-	// Set up a defered function to catch a return value from the body
+// Run the body of the function once, returning its value, or the tail call that it returned instead
+func (f *Function) run(i *Interpreter, arguments []any) (returnValue any, tail *TailCall) {
+	currEnvironment := f.bindParams(i, arguments)
+	if i.enforceTypes {
+		i.enforceParams(f, currEnvironment)
+	}
+
+	// Generator functions only run their body as the generator is resumed
+	// The body is nested in this call, so it is checked against the depth limit here
+	if f.declaration.isGenerator {
+		i.sandbox.checkDepth(i.depth+1, f.declaration.name)
+		return newGenerator(i, f.declaration.body, currEnvironment), nil
+	}
+
+	// The call counts toward the depth limit until it returns
+	i.depth++
+	if i.profiler != nil {
+		i.enterCall(f.profileName())
+		defer i.exitCall()
+	}
+
+	// Set up a defered function to catch a return value or tail call from the body
 	defer func() {
+		i.depth--
 		if r := recover(); r != nil {
-			if caughtValue, ok := r.(Return); ok {
+			switch caught := r.(type) {
+			case Return:
 				if f.isInitializer {
 					returnValue = f.closure.getAt(0, "this")
 				} else {
-					returnValue = caughtValue.value
+					returnValue = caught.value
 				}
-			} else {
+			case TailCall:
+				tail = &caught
+			default:
 				panic(r)
 			}
 		}
 	}()
 
+	i.sandbox.checkDepth(i.depth, f.declaration.name)
 	i.executeBlock(f.declaration.body, currEnvironment)
 
 	if f.isInitializer {
-		return f.closure.getAt(0, "this")
+		return f.closure.getAt(0, "this"), nil
+	}
+	return nil, nil
+}
+
+// Bind the arguments to the parameters in a new environment for the body
+func (f *Function) bindParams(i *Interpreter, arguments []any) *Environment {
+	currEnvironment := &Environment{enclosing: f.closure, values: map[string]any{}}
+	for j, param := range f.declaration.params {
+		if param.isRest {
+			// Collect all remaining arguments
+			rest := []any{}
+			if j < len(arguments) {
+				rest = append(rest, arguments[j:]...)
+			}
+			i.sandbox.allocate(len(rest), param.name)
+			currEnvironment.define(param.name.lexeme, Sequence{list: rest, isString: false})
+		} else if j < len(arguments) && !isMissing(arguments[j]) {
+			currEnvironment.define(param.name.lexeme, arguments[j])
+		} else if param.defaultValue != nil {
+			// Defaults are evaluated at call time, and can see the earlier parameters
+			currEnvironment.define(param.name.lexeme,
+				i.evaluateIn(param.defaultValue, currEnvironment))
+		} else {
+			currEnvironment.define(param.name.lexeme, nil)
+		}
+	}
+	return currEnvironment
+}
+
+// Fill in the arguments that were left out with the defaults of their parameters
+// The defaults are evaluated here, so calling the function with the result doesn't evaluate them again
+func (f *Function) withDefaults(i *Interpreter, arguments []any) []any {
+	env := f.bindParams(i, arguments)
+	filled := []any{}
+	for _, param := range f.declaration.params {
+		value := env.getAt(0, param.name.lexeme)
+		if param.isRest {
+			filled = append(filled, value.(Sequence).list...)
+		} else {
+			filled = append(filled, value)
+		}
 	}
-	return nil
+	i.sandbox.allocate(len(filled), f.declaration.name)
+	return filled
 }
@@ -0,0 +1,163 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Shared state between a generator's goroutine and whoever resumes it
+// Only one side runs at a time, handing control back and forth over the channels
+// Tasks (and the finalizer) may resume or close the same generator at once, so the flags are locked
+type generatorState struct {
+	resume   chan bool            // Sends true to run until the next yield, or false to abandon the generator
+	results  chan generatorResult // Receives each yielded value, and a final result when the body finishes
+	start    func()               // Starts the body's goroutine on the first resume
+	lock     sync.Mutex
+	started  bool
+	running  bool
+	finished bool
+}
+
+// A value handed out of a generator's goroutine
+type generatorResult struct {
+	value    any
+	finished bool // Whether the body has finished, in which case value is nil
+	err      any  // Anything panicked by the body, to be panicked again by whoever resumed it
+}
+
+// Panicked inside an abandoned generator's goroutine to unwind its body
+type generatorClosed struct{}
+
+// Holds a generator's state for the natives of its instance
+// Once the instance is unreachable, the finalizer on the handle shuts down the goroutine
+type generatorHandle struct {
+	state *generatorState
+}
+
+// Build a generator, a native instance that runs the body of a generator function up to each yield
+// Its next method makes it usable as an iterator, returning nil once the body has finished
+func newGenerator(i *Interpreter, body []Stmt, env *Environment) *Instance {
+	state := &generatorState{resume: make(chan bool), results: make(chan generatorResult)}
+	handle := &generatorHandle{state: state}
+	runtime.SetFinalizer(handle, func(handle *generatorHandle) {
+		handle.state.close()
+	})
+
+	// The body runs with its own copy of the interpreter, so its environment stays separate
+	generatorInterpreter := i.fork(env)
+	generatorInterpreter.generator = state
+	generatorInterpreter.depth++
+	state.start = func() {
+		go generatorInterpreter.runGenerator(body)
+	}
+
+	return newNativeInstance("Generator", map[string]any{
+		"next": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				return handle.state.next()
+			},
+		},
+		"done": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				return handle.state.isFinished()
+			},
+		},
+		"close": &Native{
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(_ *Interpreter, _ []any) any {
+				handle.state.close()
+				return nil
+			},
+		},
+	})
+}
+
+// Body of a generator's goroutine, which waits to be resumed before running
+func (i *Interpreter) runGenerator(body []Stmt) {
+	state := i.generator
+
+	// Set up a defered function that reports how the body finished
+	defer func() {
+		r := recover()
+		switch r.(type) {
+		case nil, Return, generatorClosed:
+			// Returning ends the generator, and any returned value is discarded
+			state.results <- generatorResult{finished: true}
+		default:
+			state.results <- generatorResult{finished: true, err: r}
+		}
+	}()
+
+	if !<-state.resume {
+		panic(generatorClosed{})
+	}
+	i.executeBlock(body, i.environment)
+}
+
+// Run the body until the next yield, returning the yielded value, or nil if the body has finished
+func (s *generatorState) next() any {
+	s.lock.Lock()
+	if s.finished {
+		s.lock.Unlock()
+		return nil
+	}
+	if s.running {
+		s.lock.Unlock()
+		panic(RuntimeError{message: "Generator is already running."})
+	}
+	s.running = true
+	if !s.started {
+		s.started = true
+		s.start()
+	}
+	s.lock.Unlock()
+
+	s.resume <- true
+	result := <-s.results
+
+	s.lock.Lock()
+	s.running = false
+	if result.finished {
+		s.finished = true
+	}
+	s.lock.Unlock()
+	if result.err != nil {
+		panic(result.err)
+	}
+	return result.value
+}
+
+// Abandon the generator, unwinding its body if it was started but has not finished
+func (s *generatorState) close() {
+	s.lock.Lock()
+	if s.finished || s.running {
+		s.lock.Unlock()
+		return
+	}
+	s.finished = true
+	started := s.started
+	s.lock.Unlock()
+
+	if started {
+		s.resume <- false
+		<-s.results
+	}
+}
+
+// Whether the body has finished, or the generator was closed
+func (s *generatorState) isFinished() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.finished
+}
+
+// Hand a value to whoever resumed the generator, then wait to be resumed again
+func (s *generatorState) yield(value any) {
+	s.results <- generatorResult{value: value}
+	if !<-s.resume {
+		panic(generatorClosed{})
+	}
+}
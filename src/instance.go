@@ -1,10 +1,20 @@
 // Ward Jaeger, CS 403
 package main
 
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
 // A particular instantiation of a class
+// Tasks can share instances, so the fields are guarded by a lock
 type Instance struct {
 	*Class
-	fields map[string]any
+	fields    map[string]any
+	lock      sync.RWMutex
+	native    any                    // Go value backing an instance built by a native, if any
+	decorated map[*Function]Callable // Decorated methods that have been bound to the instance
 }
 
 // String representation
@@ -12,21 +22,94 @@ func (i *Instance) toString() string {
 	return i.name + " instance"
 }
 
-// Get property or bound method
-func (i *Instance) get(name Token) any {
-	if property, found := i.fields[name.lexeme]; found {
+// Get property or bound method, calling it if it is a getter
+func (i *Instance) get(interpreter *Interpreter, name Token) any {
+	if property, found := i.getField(name.lexeme); found {
 		return property
 	}
 
 	if method := i.findMethod(name.lexeme); method != nil {
-		return method.bind(i)
+		if method.declaration.isGetter {
+			return i.bindMethod(interpreter, method).call(interpreter, []any{})
+		}
+		return i.bindMethod(interpreter, method)
 	}
 
 	panic(RuntimeError{token: name,
 		message: "Undefined property '" + name.lexeme + "'."})
 }
 
-// Set property
-func (i *Instance) set(name Token, value any) {
-	i.fields[name.lexeme] = value
+// Bind a method to the instance, passing it through the method's decorators the first time it is bound
+// The decorated method is kept, so that decorators like memoize hold onto their state between calls
+func (i *Instance) bindMethod(interpreter *Interpreter, method *Function) Callable {
+	if len(method.decorators) == 0 {
+		return method.bind(i)
+	}
+	i.lock.RLock()
+	decorated, found := i.decorated[method]
+	i.lock.RUnlock()
+	if found {
+		return decorated
+	}
+
+	// The decorators can run any code, so the lock isn't held while they do
+	value := interpreter.applyDecorators(method.bind(i), method.decorators, method.declaration.name)
+	decorated, ok := value.(Callable)
+	if !ok {
+		panic(RuntimeError{token: method.declaration.name,
+			message: "Decorators of a method must return a function or class."})
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if existing, found := i.decorated[method]; found {
+		return existing
+	}
+	if i.decorated == nil {
+		i.decorated = map[*Function]Callable{}
+	}
+	i.decorated[method] = decorated
+	return decorated
+}
+
+// Set property, calling a setter if there is one
+func (i *Instance) set(interpreter *Interpreter, name Token, value any) {
+	if setter, found := i.setters[name.lexeme]; found {
+		i.bindMethod(interpreter, setter).call(interpreter, []any{value})
+		return
+	}
+	i.setField(name.lexeme, value)
+}
+
+// Look up a field by name, noting whether it exists
+func (i *Instance) getField(name string) (any, bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	value, found := i.fields[name]
+	return value, found
+}
+
+// Set a field by name
+func (i *Instance) setField(name string, value any) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.fields[name] = value
+}
+
+// Names of all public fields, in sorted order
+func (i *Instance) fieldNames() []string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	names := []string{}
+	for name := range i.fields {
+		if !isPrivate(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check if the name of a field or method is private
+func isPrivate(name string) bool {
+	return strings.HasPrefix(name, "#")
 }
@@ -3,50 +3,129 @@ package main
 
 import (
 	"fmt"
+	"sort"
 )
 
 // Visitor pattern that evaluates an entire program of statements
 type Interpreter struct {
-	environment *Environment
-	globals     *Environment
-	locals      map[Expr]int
+	environment  *Environment
+	globals      *Environment
+	locals       map[Expr]int
+	owners       map[Expr]*ClassStmt // Classes that private members are used in
+	constants    map[string]bool     // Global constants, which are kept across runs of the prompt
+	generator    *generatorState     // Generator whose body is being run, if any
+	sandbox      *sandbox            // Limits on the run, shared with tasks and generators
+	failedTasks  *taskFailures       // Tasks that ended with an error, shared with tasks and generators
+	depth        int                 // Function calls currently nested on this goroutine
+	nativeCall   Token               // Call to the native being run on this goroutine, for errors from special methods it calls
+	lines        map[Stmt]int        // Lines that statements start on
+	profiler     *profiler           // nil unless the run is being profiled
+	coverage     *coverage           // nil unless coverage is being recorded
+	tracer       *tracer             // nil unless the run is being traced
+	traceNames   []string            // Names of the functions being called on this goroutine, when tracing
+	tracedDepth  int                 // Calls to functions decorated with trace currently nested on this goroutine
+	calls        []profileFrame      // Calls being timed on this goroutine
+	statements   []profileFrame      // Statements being timed on this goroutine
+	enforceTypes bool                // Whether annotated parameters and return values are checked at calls
 }
 
 // Test for interface implementation
 var _ ExprVisitor = &Interpreter{}
 var _ StmtVisitor = &Interpreter{}
 
+// Copy the interpreter to run code on another goroutine, starting from the given environment
+// Fields that follow the calls on one goroutine start fresh, while the sandbox, profiler, coverage, and tracer
+// are shared by every copy, so they are atomic or locked
+// The call depth carries over, so that recursing through tasks or generators still counts toward the depth limit
+func (i *Interpreter) fork(env *Environment) *Interpreter {
+	forked := *i
+	forked.environment = env
+	forked.generator = nil
+	forked.tracedDepth = 0
+	forked.traceNames = nil
+	forked.calls, forked.statements = nil, nil
+	return &forked
+}
+
 // Entry point for interpretation
 func (i *Interpreter) interpret(statements []Stmt) {
+	// Each run gets a sandbox of its own, so that tasks left over from an earlier run in the REPL keep to theirs
+	i.sandbox.cancel()
+	i.sandbox = newSandbox(i.sandbox.limits)
+	if i.profiler != nil {
+		i.enterCall(scriptFrame)
+		defer i.exitCall()
+	}
+
 	// Set up defered function to catch and report runtime errors
 	defer func() {
 		if r := recover(); r != nil {
 			if err, ok := r.(RuntimeError); ok {
 				reportRuntime(err)
+			} else if err, ok := r.(LimitError); ok {
+				reportLimit(err)
 			} else {
 				panic(r)
 			}
 		}
+		i.failedTasks.reportUnjoined()
 	}()
 
 	for _, statement := range statements {
 		i.execute(statement)
 	}
+	// A task may exceed a limit after the last statement has started
+	i.sandbox.checkFailure()
 }
 
 // Pass interpreter to statements and expressions
 func (i *Interpreter) execute(stmt Stmt) {
+	i.sandbox.step()
+	if i.tracer != nil {
+		i.traceStatement(stmt)
+	}
+	if i.coverage != nil {
+		if line, ok := i.lines[stmt]; ok {
+			i.coverage.hitLine(line)
+		}
+	}
+	if i.profiler != nil && i.profiler.withLines {
+		if line, ok := i.lines[stmt]; ok {
+			i.enterStatement(line)
+			defer i.exitStatement()
+		}
+	}
 	stmt.accept(i)
 }
 func (i *Interpreter) evaluate(expr Expr) any {
 	return expr.accept(i)
 }
 
+// Count an arm of a branch being taken, if coverage is being recorded
+func (i *Interpreter) takeBranch(node any, arm int) {
+	if i.coverage != nil {
+		i.coverage.takeBranch(node, arm)
+	}
+}
+
 // Helper function for Interpreter that converts a value to a boolean
 func isTruthy(value any) bool {
 	return value != nil && value != false
 }
 
+// Evaluate an expression in a given environment
+func (i *Interpreter) evaluateIn(expr Expr, env *Environment) any {
+	previous := i.environment
+	i.environment = env
+
+	// Set up a defered function that returns the environment to its original state
+	defer func() {
+		i.environment = previous
+	}()
+
+	return i.evaluate(expr)
+}
+
 // Execute a list of statements in a given environment
 func (i *Interpreter) executeBlock(statements []Stmt, env *Environment) {
 	previous := i.environment
@@ -67,6 +146,29 @@ func (i *Interpreter) resolve(expr Expr, depth int) {
 	i.locals[expr] = depth
 }
 
+// Called by the Resolver to note which class a private member is used in
+func (i *Interpreter) resolveOwner(expr Expr, class *ClassStmt) {
+	i.owners[expr] = class
+}
+
+// Check that a private member is only used on an instance (or the class itself) of the class it is used in
+func (i *Interpreter) checkPrivate(expr Expr, object any, name Token) {
+	if name.tokenType != PRIVATE_NAME {
+		return
+	}
+
+	var class *Class
+	if instance, ok := object.(*Instance); ok {
+		class = instance.Class
+	} else if c, ok := object.(*Class); ok {
+		class = c
+	}
+	if class == nil || class.declaration != i.owners[expr] {
+		panic(RuntimeError{token: name, message: "Can't access private member '" + name.lexeme +
+			"' from outside of its class."})
+	}
+}
+
 // Return variable at correct depth, or at global level
 func (i *Interpreter) lookUpVariable(name Token, expr Expr) any {
 	if distance, found := i.locals[expr]; found {
@@ -89,12 +191,32 @@ func (i *Interpreter) visitClassStmt(stmt *ClassStmt) any {
 	methods := map[string]*Function{}
 	for _, method := range stmt.methods {
 		function := &Function{declaration: method, closure: i.environment,
-			isInitializer: method.name.lexeme == "init"}
+			isInitializer: method.name.lexeme == "init", decorators: i.evaluateDecorators(method)}
 		methods[method.name.lexeme] = function
 	}
+	setters := map[string]*Function{}
+	for _, setter := range stmt.setters {
+		setters[setter.name.lexeme] = &Function{declaration: setter, closure: i.environment,
+			decorators: i.evaluateDecorators(setter)}
+	}
+	i.includeTraits(stmt, methods)
+	statics := map[string]any{}
+	for _, method := range stmt.staticMethods {
+		statics[method.name.lexeme] = i.decorate(&Function{declaration: method, closure: i.environment})
+	}
 
-	class := &Class{name: stmt.name.lexeme, methods: methods}
+	class := &Class{name: stmt.name.lexeme, declaration: stmt, methods: methods,
+		setters: setters, statics: statics}
 	i.environment.assign(stmt.name, class)
+
+	// Static fields are initialized in order, once the class exists
+	for _, field := range stmt.staticFields {
+		var value any
+		if field.initializer != nil {
+			value = i.evaluate(field.initializer)
+		}
+		class.setStatic(field.name, value)
+	}
 	return nil
 }
 
@@ -104,25 +226,85 @@ func (i *Interpreter) visitExpressionStmt(stmt *ExpressionStmt) any {
 	return nil
 }
 
+// Execute the body once for each element of the iterable, with the loop variables in a fresh scope
+func (i *Interpreter) visitForInStmt(stmt *ForInStmt) any {
+	i.iterate(i.evaluate(stmt.iterable), stmt.name, func(element any) {
+		env := &Environment{enclosing: i.environment, values: map[string]any{}}
+		if stmt.pattern == nil {
+			env.define(stmt.name.lexeme, element)
+		} else if reason := i.matchPattern(stmt.pattern, element, env); reason != "" {
+			panic(RuntimeError{token: stmt.name, message: "Can't destructure value. " + reason})
+		}
+		i.executeBlock([]Stmt{stmt.body}, env)
+	})
+	return nil
+}
+
+// Call a function with each element of a list, each character of a string,
+// or each value of an iterator until its next method returns nil
+func (i *Interpreter) iterate(iterable any, token Token, each func(any)) {
+	switch iterable := iterable.(type) {
+	case Sequence:
+		for _, element := range iterable.elements() {
+			if iterable.isString {
+				element = Sequence{list: []any{element}, isString: true}
+			}
+			each(element)
+		}
+		return
+	case *Instance:
+		next, found := iterable.getField("next")
+		if !found {
+			if method := iterable.findMethod("next"); method != nil {
+				next = iterable.bindMethod(i, method)
+			}
+		}
+		if callable, ok := next.(Callable); ok {
+			for {
+				element := callable.call(i, bindArguments(callable, []any{}, map[string]any{}, token))
+				if element == nil {
+					return
+				}
+				each(element)
+			}
+		}
+	}
+	panic(RuntimeError{token: token, message: "Can only iterate over lists, strings, and iterators."})
+}
+
 // Define a new function
 func (i *Interpreter) visitFunctionStmt(stmt *FunctionStmt) any {
 	function := &Function{declaration: stmt, closure: i.environment}
-	i.environment.define(stmt.name.lexeme, function)
+	i.environment.define(stmt.name.lexeme, i.decorate(function))
 	return nil
 }
 
 // If condition is true, execute thenBranch, otherwise elseBranch if it exists
 func (i *Interpreter) visitIfStmt(stmt *IfStmt) any {
 	if isTruthy(i.evaluate(stmt.condition)) {
+		i.takeBranch(stmt, 0)
 		i.execute(stmt.thenBranch)
-	} else if stmt.elseBranch != nil {
-		i.execute(stmt.elseBranch)
+	} else {
+		i.takeBranch(stmt, 1)
+		if stmt.elseBranch != nil {
+			i.execute(stmt.elseBranch)
+		}
 	}
 	return nil
 }
 
 // Throw a Return value up the call stack to be caught by function call
 func (i *Interpreter) visitReturnStmt(stmt *ReturnStmt) any {
+	// A call to a function is thrown to the caller to make, unless each call is being traced
+	if stmt.isTailCall && i.tracer == nil {
+		call := stmt.value.(*CallExpr)
+		callable, arguments := i.evaluateCall(call)
+		if f, ok := callable.(*Function); ok && !f.declaration.isGenerator {
+			panic(TailCall{function: f, arguments: arguments})
+		}
+		panic(Return{value: i.callWithToken(callable, arguments, call.paren)})
+	}
+
 	if stmt.value != nil {
 		panic(Return{value: i.evaluate(stmt.value)})
 	}
@@ -130,13 +312,32 @@ func (i *Interpreter) visitReturnStmt(stmt *ReturnStmt) any {
 	panic(Return{value: nil})
 }
 
-// Define (default to nil) a new variable in the current scope
+// Define a new trait
+func (i *Interpreter) visitTraitStmt(stmt *TraitStmt) any {
+	methods := map[string]*Function{}
+	for _, method := range stmt.methods {
+		methods[method.name.lexeme] = &Function{declaration: method, closure: i.environment}
+	}
+
+	i.environment.define(stmt.name.lexeme,
+		&Trait{name: stmt.name.lexeme, required: stmt.required, methods: methods})
+	return nil
+}
+
+// Define (default to nil) a new variable in the current scope, or destructure into several
 func (i *Interpreter) visitVarStmt(stmt *VarStmt) any {
 	var value any
 	if stmt.initializer != nil {
 		value = i.evaluate(stmt.initializer)
 	}
 
+	if stmt.pattern != nil {
+		if reason := i.matchPattern(stmt.pattern, value, i.environment); reason != "" {
+			panic(RuntimeError{token: stmt.name, message: "Can't destructure value. " + reason})
+		}
+		return nil
+	}
+
 	i.environment.define(stmt.name.lexeme, value)
 	return nil
 }
@@ -152,123 +353,162 @@ func (i *Interpreter) visitWhileStmt(stmt *WhileStmt) any {
 // Assign variable to new value
 func (i *Interpreter) visitAssignExpr(expr *AssignExpr) any {
 	value := i.evaluate(expr.value)
+	i.assignVariable(expr.name, expr, value)
+	return value
+}
 
+// Assign a variable at the correct depth, or at global level
+func (i *Interpreter) assignVariable(name Token, expr Expr, value any) {
 	if distance, found := i.locals[expr]; found {
-		i.environment.assignAt(distance, expr.name, value)
+		i.environment.assignAt(distance, name, value)
 	} else {
-		i.globals.assign(expr.name, value)
+		i.globals.assign(name, value)
 	}
+}
 
+// Assign the elements of a list to several targets
+func (i *Interpreter) visitDestructureExpr(expr *DestructureExpr) any {
+	value := i.evaluate(expr.value)
+	i.assignTargets(expr.targets, value, expr.equals)
 	return value
 }
 
+// Assign the elements of a list (or string) to a list of targets, with an optional spread at the end
+func (i *Interpreter) assignTargets(targets []Expr, value any, equals Token) {
+	sequence, ok := value.(Sequence)
+	if !ok {
+		panic(RuntimeError{token: equals, message: "Can only destructure strings and lists."})
+	}
+
+	count := len(targets)
+	spread, hasSpread := (*SpreadExpr)(nil), false
+	if count > 0 {
+		spread, hasSpread = targets[count-1].(*SpreadExpr)
+	}
+	if hasSpread {
+		count--
+		if sequence.size() < count {
+			panic(RuntimeError{token: equals, message: "Expect at least " +
+				fmt.Sprint(count) + " elements but got " + fmt.Sprint(sequence.size()) + "."})
+		}
+	} else if sequence.size() != count {
+		panic(RuntimeError{token: equals, message: "Expect " +
+			fmt.Sprint(count) + " elements but got " + fmt.Sprint(sequence.size()) + "."})
+	}
+
+	for j, target := range targets[:count] {
+		element := sequence.element(j)
+		if sequence.isString {
+			// Elements of a string are strings of length 1
+			element = Sequence{list: []any{element}, isString: true}
+		}
+		i.assignTarget(target, element, equals)
+	}
+	if hasSpread {
+		rest := sequence.slice(count, sequence.size())
+		i.assignTarget(spread.expression, Sequence{list: rest, isString: sequence.isString}, equals)
+	}
+}
+
+// Assign a value to a single destructuring target
+func (i *Interpreter) assignTarget(target Expr, value any, equals Token) {
+	switch target := target.(type) {
+	case *VariableExpr:
+		i.assignVariable(target.Token, target, value)
+	case *GetExpr:
+		object := i.evaluate(target.object)
+		i.checkPrivate(target, object, target.name)
+		i.setProperty(object, target.name, value)
+	case *IndexExpr:
+		i.replaceElement(i.evaluate(target.indexee), i.evaluate(target.start), value, target.bracket)
+	case *ListExpr:
+		i.assignTargets(target.elements, value, equals)
+	}
+}
+
 // Perform (arithmetic/comparison/concatenation) operation on two values
 func (i *Interpreter) visitBinaryExpr(expr *BinaryExpr) any {
 	left := i.evaluate(expr.left)
 	right := i.evaluate(expr.right)
 
-	switch expr.operator.tokenType {
-	case GREATER:
-		// Greater than
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l > r
-			}
-		}
-		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
+	// Instances may overload the operator with a special method
+	if result, ok := i.overloadBinary(expr.operator, left, right); ok {
+		return result
+	}
 
-	case GREATER_EQUAL:
-		// Greater than or equal to
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l >= r
-			}
+	switch expr.operator.tokenType {
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		// Ordering, where comparisons with NaN are always false
+		if !isNumber(left) || !isNumber(right) {
+			panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
 		}
-		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
-
-	case LESS:
-		// Less than
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l < r
-			}
+		order, ok := compareNumbers(left, right)
+		if !ok {
+			return false
 		}
-		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
-
-	case LESS_EQUAL:
-		// Less than or equal to
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l <= r
-			}
+		switch expr.operator.tokenType {
+		case GREATER:
+			return order > 0
+		case GREATER_EQUAL:
+			return order >= 0
+		case LESS:
+			return order < 0
 		}
-		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
+		return order <= 0
 
 	case BANG_EQUAL:
 		// Not equal
-		return !compare(left, right)
+		return !i.compare(left, right, expr.operator)
 
 	case EQUAL_EQUAL:
 		// Equal
-		return compare(left, right)
+		return i.compare(left, right, expr.operator)
 
-	case MINUS:
-		fallthrough
-	case MINUS_EQUAL:
-		fallthrough
-	case MINUS_MINUS:
+	case MINUS, MINUS_EQUAL, MINUS_MINUS:
 		// Subtraction
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l - r
-			}
+		if result, ok := arithmetic(MINUS, left, right); ok {
+			return result
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
 
-	case PLUS:
-		fallthrough
-	case PLUS_EQUAL:
-		fallthrough
-	case PLUS_PLUS:
+	case PLUS, PLUS_EQUAL, PLUS_PLUS:
 		// Addition
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l + r
-			}
+		if result, ok := arithmetic(PLUS, left, right); ok {
+			return result
 		}
 		// Concatenation
 		if l, ok := left.(Sequence); ok {
 			if r, ok := right.(Sequence); ok {
 				if l.isString == r.isString {
-					newList := append(append([]any{}, l.list...), r.list...)
+					newList := append(l.elements(), r.elements()...)
+					i.sandbox.allocate(len(newList), expr.operator)
 					return Sequence{list: newList, isString: l.isString}
 				}
 			}
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operands must be two numbers, two strings, or two lists."})
 
-	case SLASH:
-		fallthrough
-	case SLASH_EQUAL:
-		// Division
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				if r == 0 {
-					// panic(RuntimeError{token: expr.operator, message: "Division by zero."})
-				}
-				return l / r
-			}
+	case SLASH, SLASH_EQUAL:
+		// Division, which always gives a float
+		if result, ok := arithmetic(SLASH, left, right); ok {
+			return result
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
 
-	case STAR:
-		fallthrough
-	case STAR_EQUAL:
+	case STAR, STAR_EQUAL:
 		// Multiplication
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l * r
-			}
+		if result, ok := arithmetic(STAR, left, right); ok {
+			return result
+		}
+		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
+
+	case TILDE_SLASH, TILDE_SLASH_EQUAL:
+		// Integer division, rounding toward negative infinity
+		if isInteger(left) && isInteger(right) && toBig(right).Sign() == 0 {
+			panic(RuntimeError{token: expr.operator, message: "Division by zero."})
+		}
+		if result, ok := arithmetic(TILDE_SLASH, left, right); ok {
+			return result
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operands must be numbers."})
 	}
@@ -277,9 +517,15 @@ func (i *Interpreter) visitBinaryExpr(expr *BinaryExpr) any {
 	panic(RuntimeError{token: expr.operator, message: "Unrecognized binary operator."})
 }
 
-// Helper function for Interpreter that compares simple values or Sequences
-// Functions, Classes, and Instances are passed around by pointer, so they do not need extra handling
-func compare(left any, right any) bool {
+// Compare simple values or Sequences, or Instances that overload equality with __eq__
+// Functions, Classes, and other Instances are passed around by pointer, so they do not need extra handling
+func (i *Interpreter) compare(left any, right any, token Token) bool {
+	if result, ok := i.callSpecial(left, "__eq__", token, right); ok {
+		return isTruthy(result)
+	} else if result, ok := i.callSpecial(right, "__eq__", token, left); ok {
+		return isTruthy(result)
+	}
+
 	// slices are not comparable, so Sequences must be handled separately
 	if l, ok := left.(Sequence); ok {
 		if r, ok := right.(Sequence); ok {
@@ -287,14 +533,16 @@ func compare(left any, right any) bool {
 				// left and right are incomparable Sequences (different size or types)
 				return false
 			}
-			for i := 0; i < l.size(); i++ {
-				if !compare(l.list[i], r.list[i]) {
+			// Copy the elements, since comparing them may call methods that modify them
+			lElements, rElements := l.elements(), r.elements()
+			for j := range lElements {
+				if !i.compare(lElements[j], rElements[j], token) {
 					// left and right are comparable Sequences, some elements are not equal
 					return false
 				}
-				// left and right are comparable Sequences, all elements are equal
-				return true
 			}
+			// left and right are comparable Sequences, all elements are equal
+			return true
 		}
 		// left is Sequence, right is not
 		return false
@@ -303,60 +551,217 @@ func compare(left any, right any) bool {
 		return false
 	}
 
+	// Integers and floats are equal when they have the same value, and big integers are pointers
+	if isNumber(left) && isNumber(right) {
+		order, ok := compareNumbers(left, right)
+		return ok && order == 0
+	}
+
 	// left and right are not Sequences, safe to equal
 	return left == right
 }
 
 // Perform a call on a Callable
 func (i *Interpreter) visitCallExpr(expr *CallExpr) any {
+	callable, arguments := i.evaluateCall(expr)
+	return i.callWithToken(callable, arguments, expr.paren)
+}
+
+// Evaluate the callee and arguments of a call, binding the arguments to the callee's parameters
+func (i *Interpreter) evaluateCall(expr *CallExpr) (Callable, []any) {
 	callee := i.evaluate(expr.callee)
+	if callee == nil && expr.optional {
+		panic(shortCircuit{})
+	}
+
+	// Instances can only be called if they overload calls with __call__
+	if instance, ok := callee.(*Instance); ok {
+		method := instance.findMethod("__call__")
+		if method == nil {
+			panic(RuntimeError{token: expr.paren,
+				message: "Can only call functions and classes."})
+		}
+		callee = instance.bindMethod(i, method)
+	}
 
-	arguments := []any{}
-	for _, argument := range expr.arguments {
-		arguments = append(arguments, i.evaluate(argument))
+	arguments := i.evaluateElements(expr.arguments)
+	keywords := map[string]any{}
+	for _, keyword := range expr.keywords {
+		keywords[keyword.name.lexeme] = i.evaluate(keyword.value)
 	}
 
 	if callable, ok := callee.(Callable); ok {
-		// Throw error if the arity doesn't match
-		if len(arguments) != callable.arity() {
-			panic(RuntimeError{
-				token: expr.paren,
-				message: "Expected " +
-					fmt.Sprint(callable.arity()) + " arguments but got " +
-					fmt.Sprint(len(arguments)) + ".",
-			})
-		}
-
-		// Native functions don't have access to tokens...
-		if _, ok := callable.(*Native); ok {
-			// ...so set up a defered function to add tokens to Runtime errors
-			defer func() {
-				if r := recover(); r != nil {
-					if err, ok := r.(RuntimeError); ok {
-						panic(RuntimeError{token: expr.paren, message: err.message})
-					} else {
-						panic(r)
-					}
+		return callable, bindArguments(callable, arguments, keywords, expr.paren)
+	}
+
+	panic(RuntimeError{token: expr.paren,
+		message: "Can only call functions and classes."})
+}
+
+// Call a callable with bound arguments, attributing errors from natives to a token
+func (i *Interpreter) callWithToken(callable Callable, arguments []any, paren Token) any {
+	// Native functions (and the decorators built into the interpreter) don't have access to tokens...
+	switch callable.(type) {
+	case *Native, *Decorated:
+		// ...so they get the token of the call to pass on to the special methods they call...
+		enclosingCall := i.nativeCall
+		i.nativeCall = paren
+		// ...and set up a defered function to add tokens to Runtime errors that lack one
+		defer func() {
+			i.nativeCall = enclosingCall
+			if r := recover(); r != nil {
+				if err, ok := r.(RuntimeError); ok && err.token == (Token{}) {
+					panic(RuntimeError{token: paren, message: err.message})
+				} else {
+					panic(r)
 				}
-			}()
+			}
+		}()
+	}
+
+	return callable.call(i, arguments)
+}
+
+// Helper function for Interpreter that checks the arity of a call,
+// and places keyword arguments into the positions of their parameters
+func bindArguments(callable Callable, arguments []any, keywords map[string]any, paren Token) []any {
+	min, max := callable.arity()
+	if len(arguments) > max && max != variadic {
+		panic(RuntimeError{token: paren, message: arityMessage(callable, len(arguments))})
+	}
+
+	// Natives take their keyword-only parameters at the end, in order
+	var params []Param
+	switch c := callable.(type) {
+	case *Native:
+		if len(arguments) < min {
+			panic(RuntimeError{token: paren, message: arityMessage(callable, len(arguments))})
+		}
+		for name := range keywords {
+			if !containsString(c.keywords, name) {
+				panic(RuntimeError{token: paren, message: callable.toString() +
+					" got an unexpected keyword argument '" + name + "'."})
+			}
+		}
+		for _, name := range c.keywords {
+			arguments = append(arguments, keywords[name])
+		}
+		return arguments
+	case *Decorated:
+		return bindArguments(c.wrapped, arguments, keywords, paren)
+	case *Function:
+		params = c.declaration.params
+	case *Class:
+		if initializer := c.findMethod("init"); initializer != nil {
+			params = initializer.declaration.params
 		}
+	}
 
-		return callable.call(i, arguments)
+	if len(keywords) == 0 {
+		if len(arguments) < min {
+			panic(RuntimeError{token: paren, message: arityMessage(callable, len(arguments))})
+		}
+		return arguments
 	}
 
-	panic(RuntimeError{token: expr.paren,
-		message: "Can only call functions and classes."})
+	// Sort the keywords, so that errors are reported consistently
+	names := []string{}
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bound := append([]any{}, arguments...)
+	for _, name := range names {
+		index := -1
+		for j, param := range params {
+			if param.name.lexeme == name && !param.isRest {
+				index = j
+			}
+		}
+
+		if index == -1 {
+			panic(RuntimeError{token: paren, message: callable.toString() +
+				" got an unexpected keyword argument '" + name + "'."})
+		} else if index < len(arguments) {
+			panic(RuntimeError{token: paren, message: callable.toString() +
+				" got multiple values for argument '" + name + "'."})
+		}
+
+		for len(bound) <= index {
+			bound = append(bound, missingArgument{})
+		}
+		bound[index] = keywords[name]
+	}
+
+	// Every required parameter must have been given an argument, one way or another
+	for j, param := range params {
+		if !param.isRest && param.defaultValue == nil && (j >= len(bound) || isMissing(bound[j])) {
+			panic(RuntimeError{token: paren, message: callable.toString() +
+				" is missing an argument for '" + param.name.lexeme + "'."})
+		}
+	}
+
+	return bound
+}
+
+// Helper function for Interpreter that describes an arity mismatch
+func arityMessage(callable Callable, count int) string {
+	min, max := callable.arity()
+	expected := fmt.Sprint(min)
+	if max == variadic {
+		expected = "at least " + expected
+	} else if max != min {
+		expected += " to " + fmt.Sprint(max)
+	}
+	return callable.toString() + " expected " + expected +
+		" arguments but got " + fmt.Sprint(count) + "."
+}
+
+// Helper function for Interpreter that checks if a string is in a list
+func containsString(list []string, str string) bool {
+	for _, element := range list {
+		if element == str {
+			return true
+		}
+	}
+	return false
 }
 
+// Evaluate a chain with optional links, which evaluates to nil if it is short-circuited
+func (i *Interpreter) visitChainExpr(expr *ChainExpr) (value any) {
+	// Set up a defered function to catch a short-circuit from an optional link
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(shortCircuit); ok {
+				value = nil
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	return i.evaluate(expr.expression)
+}
+
+// Empty struct thrown by an optional link applied to nil, caught by the enclosing chain
+type shortCircuit struct{}
+
 // Get value of instance property
 func (i *Interpreter) visitGetExpr(expr *GetExpr) any {
 	object := i.evaluate(expr.object)
+	if object == nil && expr.optional {
+		panic(shortCircuit{})
+	}
+	i.checkPrivate(expr, object, expr.name)
 	if instance, ok := object.(*Instance); ok {
-		return instance.get(expr.name)
+		return instance.get(i, expr.name)
+	} else if class, ok := object.(*Class); ok {
+		return class.getStatic(i, expr.name)
 	}
 
 	panic(RuntimeError{token: expr.name,
-		message: "Only instances have properties."})
+		message: "Only instances and classes have properties."})
 }
 
 // Parentheses
@@ -367,15 +772,29 @@ func (i *Interpreter) visitGroupingExpr(expr *GroupingExpr) any {
 // Get index or slice copy of a Sequence
 func (i *Interpreter) visitIndexExpr(expr *IndexExpr) any {
 	indexee := i.evaluate(expr.indexee)
+	if indexee == nil && expr.optional {
+		panic(shortCircuit{})
+	}
+
+	// Instances may overload indexing with __index__, which also receives the stop index for slices
+	if _, ok := indexee.(*Instance); ok {
+		args := []any{i.evaluate(expr.start)}
+		if expr.stop != nil {
+			args = append(args, i.evaluate(expr.stop))
+		}
+		if result, ok := i.callSpecial(indexee, "__index__", expr.bracket, args...); ok {
+			return result
+		}
+	}
 
 	// Only try indexing on a Sequence
 	if sequence, ok := indexee.(Sequence); ok {
 		start := i.evaluate(expr.start)
 		// Only continue indexing if the start is a number or is omitted
-		if startF, ok := start.(float64); ok || start == nil {
+		if startN, ok := toIndex(start); ok || start == nil {
 			startI := 0
 			if ok {
-				startI = int(startF)
+				startI = startN
 				// Negative indexing
 				if startI < 0 {
 					startI += sequence.size()
@@ -393,12 +812,12 @@ func (i *Interpreter) visitIndexExpr(expr *IndexExpr) any {
 				} else if sequence.isString {
 					// String case
 					return Sequence{
-						list:     []any{sequence.list[startI]},
+						list:     []any{sequence.element(startI)},
 						isString: true,
 					}
 				} else {
 					// List case
-					return sequence.list[startI]
+					return sequence.element(startI)
 				}
 			}
 
@@ -408,10 +827,10 @@ func (i *Interpreter) visitIndexExpr(expr *IndexExpr) any {
 			}
 			stop := i.evaluate(expr.stop)
 			// Only continue slicing if the stop is a number or is omitted
-			if stopF, ok := stop.(float64); ok || stop == nil {
+			if stopN, ok := toIndex(stop); ok || stop == nil {
 				stopI := sequence.size()
 				if ok {
-					stopI = int(stopF)
+					stopI = stopN
 					if stopI < 0 {
 						stopI += sequence.size()
 					}
@@ -429,7 +848,8 @@ func (i *Interpreter) visitIndexExpr(expr *IndexExpr) any {
 				} else {
 					// Normal case
 					// Get shallow copy of the sequence (Instance is copied by reference)
-					return Sequence{list: append([]any{}, sequence.list[startI:stopI]...),
+					i.sandbox.allocate(stopI-startI, expr.bracket)
+					return Sequence{list: sequence.slice(startI, stopI),
 						isString: sequence.isString}
 				}
 			}
@@ -445,15 +865,51 @@ func (i *Interpreter) visitIndexExpr(expr *IndexExpr) any {
 
 // Create a new list
 func (i *Interpreter) visitListExpr(expr *ListExpr) any {
-	elements := []any{}
+	elements := i.evaluateElements(expr.elements)
+
+	// Spread elements were already counted as they were spread
+	spreads := 0
 	for _, element := range expr.elements {
-		elements = append(elements, i.evaluate(element))
+		if _, ok := element.(*SpreadExpr); ok {
+			spreads++
+		}
 	}
+	i.sandbox.allocate(len(expr.elements)-spreads, expr.bracket)
 	return Sequence{list: elements, isString: false}
 }
 
+// Evaluate a list of arguments or elements, expanding any spreads
+func (i *Interpreter) evaluateElements(exprs []Expr) []any {
+	elements := []any{}
+	for _, expr := range exprs {
+		if spread, ok := expr.(*SpreadExpr); ok {
+			value := i.evaluate(spread.expression)
+			sequence, ok := value.(Sequence)
+			if !ok {
+				panic(RuntimeError{token: spread.ellipsis,
+					message: "Can only spread strings and lists."})
+			}
+			i.sandbox.allocate(len(sequence.list), spread.ellipsis)
+			if sequence.isString {
+				// Strings spread into their characters
+				for _, char := range sequence.elements() {
+					elements = append(elements, Sequence{list: []any{char}, isString: true})
+				}
+			} else {
+				elements = append(elements, sequence.elements()...)
+			}
+		} else {
+			elements = append(elements, i.evaluate(expr))
+		}
+	}
+	return elements
+}
+
 // A literal value that needs no additional evaluation
 func (i *Interpreter) visitLiteralExpr(expr *LiteralExpr) any {
+	if sequence, ok := expr.value.(Sequence); ok && expr.folded {
+		return Sequence{list: sequence.elements(), isString: sequence.isString}
+	}
 	return expr.value
 }
 
@@ -464,10 +920,19 @@ func (i *Interpreter) visitLogicalExpr(expr *LogicalExpr) any {
 	switch expr.operator.tokenType {
 	case AND:
 		if !isTruthy(left) {
+			i.takeBranch(expr, 0)
 			return left
 		}
 	case OR:
 		if isTruthy(left) {
+			i.takeBranch(expr, 0)
+			return left
+		}
+	case QUESTION_QUESTION:
+		fallthrough
+	case QUESTION_QUESTION_EQUAL:
+		if left != nil {
+			i.takeBranch(expr, 0)
 			return left
 		}
 	default:
@@ -475,26 +940,63 @@ func (i *Interpreter) visitLogicalExpr(expr *LogicalExpr) any {
 		panic(RuntimeError{token: expr.operator, message: "Unrecognized logical operator."})
 	}
 
+	i.takeBranch(expr, 1)
 	return i.evaluate(expr.right)
 }
 
+// Evaluate the first arm whose pattern matches the subject, or nil if none match
+func (i *Interpreter) visitMatchExpr(expr *MatchExpr) any {
+	subject := i.evaluate(expr.subject)
+
+	for _, arm := range expr.arms {
+		// Each arm gets its own environment for its bindings
+		env := &Environment{enclosing: i.environment, values: map[string]any{}}
+		matched := arm.patterns == nil
+		for _, pattern := range arm.patterns {
+			for _, name := range arm.bindings {
+				env.define(name.lexeme, nil)
+			}
+			if i.matchPattern(pattern, subject, env) == "" {
+				matched = true
+				break
+			}
+		}
+
+		if matched && (arm.guard == nil || isTruthy(i.evaluateIn(arm.guard, env))) {
+			if arm.body != nil {
+				i.executeBlock([]Stmt{arm.body}, env)
+				return nil
+			}
+			return i.evaluateIn(arm.value, env)
+		}
+	}
+
+	return nil
+}
+
 // Replace an element of a Sequence at a given index
 func (i *Interpreter) visitReplaceExpr(expr *ReplaceExpr) any {
 	indexee := i.evaluate(expr.indexee)
+	value := i.evaluate(expr.value)
+	index := i.evaluate(expr.index)
+	return i.replaceElement(indexee, index, value, expr.bracket)
+}
+
+// Replace an element of an evaluated Sequence, or of an Instance that overloads __setindex__
+func (i *Interpreter) replaceElement(indexee any, index any, value any, bracket Token) any {
+	if _, ok := i.callSpecial(indexee, "__setindex__", bracket, index, value); ok {
+		return value
+	}
 
 	// Only try indexing on a Sequence
 	if sequence, ok := indexee.(Sequence); ok {
-		value := i.evaluate(expr.value)
-
-		index := i.evaluate(expr.index)
 		// Only continue indexing if the index is a number
-		if indexF, ok := index.(float64); ok {
-			indexI := int(indexF)
+		if indexI, ok := toIndex(index); ok {
 			if indexI < 0 {
 				indexI += sequence.size()
 			}
 			if indexI < 0 || indexI >= sequence.size() {
-				panic(RuntimeError{token: expr.bracket,
+				panic(RuntimeError{token: bracket,
 					message: "Index out of range."})
 			}
 
@@ -502,46 +1004,69 @@ func (i *Interpreter) visitReplaceExpr(expr *ReplaceExpr) any {
 				// Only replace character if the value is a string of length 1
 				if char, ok := value.(Sequence); ok &&
 					char.isString && char.size() == 1 {
-					sequence.list[indexI] = char.list[0]
+					sequence.setElement(indexI, char.list[0])
 					return value
 				}
 
-				panic(RuntimeError{token: expr.bracket,
+				panic(RuntimeError{token: bracket,
 					message: "Replace value must be string of length 1."})
 			} else {
 				// Replace list element no matter what
-				sequence.list[indexI] = value
+				sequence.setElement(indexI, value)
 				return value
 			}
 		}
 
-		panic(RuntimeError{token: expr.bracket,
+		panic(RuntimeError{token: bracket,
 			message: "Index must be a number."})
 	}
 
-	panic(RuntimeError{token: expr.bracket,
+	panic(RuntimeError{token: bracket,
 		message: "Can only index strings and lists."})
 }
 
 // Set value of instance field
 func (i *Interpreter) visitSetExpr(expr *SetExpr) any {
 	object := i.evaluate(expr.object)
+	value := i.evaluate(expr.value)
+	i.checkPrivate(expr, object, expr.name)
+	i.setProperty(object, expr.name, value)
+	return value
+}
 
+// Set a property of an evaluated instance, or a static field of a class
+func (i *Interpreter) setProperty(object any, name Token, value any) {
 	if instance, ok := object.(*Instance); ok {
-		value := i.evaluate(expr.value)
-		instance.set(expr.name, value)
-		return value
+		instance.set(i, name, value)
+		return
+	} else if class, ok := object.(*Class); ok {
+		class.setStatic(name, value)
+		return
 	}
 
-	panic(RuntimeError{token: expr.name,
-		message: "Only instances have fields."})
+	panic(RuntimeError{token: name,
+		message: "Only instances and classes have fields."})
+}
+
+// Start a call on its own task, evaluating the callee and arguments first
+func (i *Interpreter) visitSpawnExpr(expr *SpawnExpr) any {
+	callable, arguments := i.evaluateCall(expr.call)
+	return newTask(i, callable, arguments, expr.call.paren)
+}
+
+// Spreads are only valid as arguments or elements, which are handled by evaluateElements
+func (i *Interpreter) visitSpreadExpr(expr *SpreadExpr) any {
+	panic(RuntimeError{token: expr.ellipsis,
+		message: "Can only spread into arguments or list elements."})
 }
 
 // If condition is true, return trueValue, otherwise falseValue
 func (i *Interpreter) visitTernaryExpr(expr *TernaryExpr) any {
 	if isTruthy(i.evaluate(expr.condition)) {
+		i.takeBranch(expr, 0)
 		return i.evaluate(expr.trueValue)
 	} else {
+		i.takeBranch(expr, 1)
 		return i.evaluate(expr.falseValue)
 	}
 }
@@ -555,17 +1080,31 @@ func (i *Interpreter) visitThisExpr(expr *ThisExpr) any {
 func (i *Interpreter) visitUnaryExpr(expr *UnaryExpr) any {
 	right := i.evaluate(expr.operand)
 
+	// Instances may overload the operator with a special method
+	if name, found := unaryMethods[expr.operator.tokenType]; found {
+		if result, ok := i.callSpecial(right, name, expr.operator); ok {
+			return result
+		}
+	}
+
 	switch expr.operator.tokenType {
+	case AWAIT:
+		if instance, ok := right.(*Instance); ok {
+			if t, ok := instance.native.(*task); ok {
+				return t.join(i)
+			}
+		}
+		panic(RuntimeError{token: expr.operator, message: "Can only await tasks."})
 	case BANG:
 		return !isTruthy(right)
 	case MINUS:
-		if r, ok := right.(float64); ok {
-			return -r
+		if isNumber(right) {
+			return negate(right)
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operand must be a number."})
 	case PLUS:
-		if r, ok := right.(float64); ok {
-			return r
+		if isNumber(right) {
+			return right
 		}
 		panic(RuntimeError{token: expr.operator, message: "Operand must be a number."})
 	}
@@ -578,3 +1117,13 @@ func (i *Interpreter) visitUnaryExpr(expr *UnaryExpr) any {
 func (i *Interpreter) visitVariableExpr(expr *VariableExpr) any {
 	return i.lookUpVariable(expr.Token, expr)
 }
+
+// Hand a value out of the running generator, evaluating to nil once it is resumed
+func (i *Interpreter) visitYieldExpr(expr *YieldExpr) any {
+	var value any
+	if expr.value != nil {
+		value = i.evaluate(expr.value)
+	}
+	i.generator.yield(value)
+	return nil
+}
@@ -0,0 +1,448 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Class of the plain instances that JSON objects are decoded into
+var objectClass = &Class{name: "Object", methods: map[string]*Function{}}
+
+// Build the json library, a native instance whose fields are JSON natives
+func jsonLibrary() *Instance {
+	return newNativeInstance("json", map[string]any{
+		"parse": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				decoder := JsonDecoder{source: expectString(args[0]), line: 1, colStart: 0}
+				return decoder.decode()
+			},
+		},
+		"stringify": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				encoder := JsonEncoder{visiting: map[any]bool{}}
+				if len(args) == 2 {
+					switch indent := args[1].(type) {
+					case nil:
+					case int64, *big.Int, float64:
+						if spaces, _ := toIndex(indent); spaces > 0 {
+							encoder.indent = strings.Repeat(" ", spaces)
+						}
+					case Sequence:
+						encoder.indent = expectString(indent)
+					default:
+						panic(RuntimeError{message: "Indent must be a number or a string."})
+					}
+				}
+
+				encoder.encode(args[0], "")
+				return newString(encoder.builder.String())
+			},
+		},
+	})
+}
+
+// Converts WIXME values into JSON text
+type JsonEncoder struct {
+	builder  strings.Builder
+	indent   string       // Indentation per level, or empty for compact output
+	visiting map[any]bool // Lists and instances currently being encoded, to detect cycles
+}
+
+// Write a value at a given level of indentation
+func (e *JsonEncoder) encode(value any, prefix string) {
+	switch value := value.(type) {
+	case nil:
+		e.builder.WriteString("null")
+	case bool:
+		e.builder.WriteString(strconv.FormatBool(value))
+	case int64, *big.Int:
+		e.builder.WriteString(fmt.Sprint(value))
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			panic(RuntimeError{message: "Can't convert NaN or Inf to JSON."})
+		}
+		// FormatFloat keeps the sign of -0
+		e.builder.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	case Sequence:
+		if value.isString {
+			e.encodeString(value.toGoString())
+			return
+		}
+
+		// Empty lists have no backing array to identify them by, but also can't form cycles
+		if value.size() > 0 {
+			e.enter(&value.list[0])
+			defer delete(e.visiting, &value.list[0])
+		}
+		e.builder.WriteString("[")
+		for i, element := range value.elements() {
+			if i != 0 {
+				e.builder.WriteString(",")
+			}
+			e.newline(prefix + e.indent)
+			e.encode(element, prefix+e.indent)
+		}
+		if value.size() > 0 {
+			e.newline(prefix)
+		}
+		e.builder.WriteString("]")
+	case *Instance:
+		e.enter(value)
+		defer delete(e.visiting, value)
+
+		// Maps are unordered, so the keys are sorted for consistent output
+		// Private fields are hidden
+		keys := value.fieldNames()
+
+		e.builder.WriteString("{")
+		for i, key := range keys {
+			if i != 0 {
+				e.builder.WriteString(",")
+			}
+			e.newline(prefix + e.indent)
+			e.encodeString(key)
+			e.builder.WriteString(":")
+			if e.indent != "" {
+				e.builder.WriteString(" ")
+			}
+			field, _ := value.getField(key)
+			e.encode(field, prefix+e.indent)
+		}
+		if len(keys) > 0 {
+			e.newline(prefix)
+		}
+		e.builder.WriteString("}")
+	case Callable:
+		panic(RuntimeError{message: "Can't convert " + value.toString() + " to JSON."})
+	case *Trait:
+		panic(RuntimeError{message: "Can't convert " + value.toString() + " to JSON."})
+	default:
+		panic(RuntimeError{message: "Can't convert " + fmt.Sprint(value) + " to JSON."})
+	}
+}
+
+// Mark a list or instance as being encoded, or throw an error if it already is
+func (e *JsonEncoder) enter(reference any) {
+	if e.visiting[reference] {
+		panic(RuntimeError{message: "Can't convert cyclic structure to JSON."})
+	}
+	e.visiting[reference] = true
+}
+
+// Start a new indented line, unless the output is compact
+func (e *JsonEncoder) newline(prefix string) {
+	if e.indent != "" {
+		e.builder.WriteString("\n" + prefix)
+	}
+}
+
+// Write a quoted string, escaping special characters
+func (e *JsonEncoder) encodeString(str string) {
+	e.builder.WriteString("\"")
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '"':
+			e.builder.WriteString("\\\"")
+		case '\\':
+			e.builder.WriteString("\\\\")
+		case '\n':
+			e.builder.WriteString("\\n")
+		case '\r':
+			e.builder.WriteString("\\r")
+		case '\t':
+			e.builder.WriteString("\\t")
+		default:
+			if c < 0x20 {
+				e.builder.WriteString("\\u00" + strconv.FormatUint(uint64(c)>>4, 16) +
+					strconv.FormatUint(uint64(c)&0xF, 16))
+			} else {
+				e.builder.WriteByte(c)
+			}
+		}
+	}
+	e.builder.WriteString("\"")
+}
+
+// Converts JSON text into WIXME values
+type JsonDecoder struct {
+	source   string // Text to decode
+	current  int    // Index of current byte
+	line     int    // Line number
+	colStart int    // Index of first byte in the line
+}
+
+// Entry point for decoding, which must consume the entire source
+func (d *JsonDecoder) decode() any {
+	value := d.value()
+	d.skipWhitespace()
+	if !d.isAtEnd() {
+		d.error("Unexpected '" + string(d.peek()) + "' after value.")
+	}
+	return value
+}
+
+// Any JSON value
+func (d *JsonDecoder) value() any {
+	d.skipWhitespace()
+	if d.isAtEnd() {
+		d.error("Unexpected end of input.")
+	}
+
+	switch c := d.peek(); {
+	case c == '{':
+		return d.object()
+	case c == '[':
+		return d.array()
+	case c == '"':
+		return newString(d.string())
+	case c == '-' || isDigit(c):
+		return d.number()
+	case d.literal("true"):
+		return true
+	case d.literal("false"):
+		return false
+	case d.literal("null"):
+		return nil
+	default:
+		d.error("Unexpected '" + string(c) + "'.")
+	}
+
+	// Unreachable
+	return nil
+}
+
+// Object, decoded into a plain instance
+func (d *JsonDecoder) object() *Instance {
+	d.advance()
+	fields := map[string]any{}
+
+	d.skipWhitespace()
+	if d.match('}') {
+		return &Instance{Class: objectClass, fields: fields}
+	}
+	for {
+		d.skipWhitespace()
+		if d.peek() != '"' {
+			d.error("Expect string for object key.")
+		}
+		key := d.string()
+		d.skipWhitespace()
+		d.expect(':', "Expect ':' after object key.")
+		fields[key] = d.value()
+		d.skipWhitespace()
+		if d.match('}') {
+			return &Instance{Class: objectClass, fields: fields}
+		}
+		d.expect(',', "Expect ',' or '}' after object value.")
+	}
+}
+
+// Array, decoded into a list
+func (d *JsonDecoder) array() Sequence {
+	d.advance()
+	elements := []any{}
+
+	d.skipWhitespace()
+	if d.match(']') {
+		return Sequence{list: elements, isString: false}
+	}
+	for {
+		elements = append(elements, d.value())
+		d.skipWhitespace()
+		if d.match(']') {
+			return Sequence{list: elements, isString: false}
+		}
+		d.expect(',', "Expect ',' or ']' after array element.")
+	}
+}
+
+// String, with all escape sequences replaced
+func (d *JsonDecoder) string() string {
+	d.advance()
+	builder := strings.Builder{}
+
+	for {
+		if d.isAtEnd() {
+			d.error("Unterminated string.")
+		}
+		c := d.advance()
+		switch {
+		case c == '"':
+			return builder.String()
+		case c < 0x20:
+			d.error("Control character in string.")
+		case c != '\\':
+			builder.WriteByte(c)
+		default:
+			switch escape := d.advance(); escape {
+			case '"', '\\', '/':
+				builder.WriteByte(escape)
+			case 'b':
+				builder.WriteByte('\b')
+			case 'f':
+				builder.WriteByte('\f')
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			case 'u':
+				r := d.hex4()
+				// Combine surrogate pairs into a single rune, where neither half can appear alone
+				if r >= 0xDC00 && r < 0xE000 {
+					d.error("Unpaired low surrogate in unicode escape sequence.")
+				} else if r >= 0xD800 && r < 0xDC00 {
+					if !strings.HasPrefix(d.source[d.current:], "\\u") {
+						d.error("Expect low surrogate after high surrogate.")
+					}
+					d.current += 2
+					low := d.hex4()
+					if low < 0xDC00 || low >= 0xE000 {
+						d.error("Expect low surrogate after high surrogate.")
+					}
+					r = 0x10000 + (r-0xD800)<<10 + (low - 0xDC00)
+				}
+				builder.WriteRune(r)
+			default:
+				d.error("Invalid escape sequence '\\" + string(escape) + "'.")
+			}
+		}
+	}
+}
+
+// Four hexadecimal digits of a unicode escape sequence
+func (d *JsonDecoder) hex4() rune {
+	if d.current+4 > len(d.source) {
+		d.error("Invalid unicode escape sequence.")
+	}
+	value, err := strconv.ParseUint(d.source[d.current:d.current+4], 16, 32)
+	if err != nil {
+		d.error("Invalid unicode escape sequence.")
+	}
+	d.current += 4
+	return rune(value)
+}
+
+// Number, following the strict JSON format
+// Numbers without a fraction or exponent are integers, except for -0, which keeps its sign as a float
+func (d *JsonDecoder) number() any {
+	start := d.current
+	d.match('-')
+
+	if d.match('0') {
+		// Leading zeros are not allowed
+	} else if isDigit(d.peek()) {
+		d.digits()
+	} else {
+		d.error("Expect digit in number.")
+	}
+
+	if d.match('.') {
+		if !isDigit(d.peek()) {
+			d.error("Expect digit after decimal point.")
+		}
+		d.digits()
+	}
+
+	if d.match('e') || d.match('E') {
+		if !d.match('+') {
+			d.match('-')
+		}
+		if !isDigit(d.peek()) {
+			d.error("Expect digit in exponent.")
+		}
+		d.digits()
+	}
+
+	text := d.source[start:d.current]
+	if text == "-0" {
+		return math.Copysign(0, -1)
+	}
+	if text[0] == '-' {
+		return negate(parseNumber(text[1:]))
+	}
+	return parseNumber(text)
+}
+
+// Consume a run of digits
+func (d *JsonDecoder) digits() {
+	for isDigit(d.peek()) {
+		d.advance()
+	}
+}
+
+// Consume a keyword literal if it is next
+func (d *JsonDecoder) literal(word string) bool {
+	if strings.HasPrefix(d.source[d.current:], word) {
+		d.current += len(word)
+		return true
+	}
+	return false
+}
+
+// Skip spaces, tabs, and line breaks
+func (d *JsonDecoder) skipWhitespace() {
+	for !d.isAtEnd() {
+		switch d.peek() {
+		case ' ', '\t', '\r', '\n':
+			d.advance()
+		default:
+			return
+		}
+	}
+}
+
+// Check if there are no more bytes to decode
+func (d *JsonDecoder) isAtEnd() bool {
+	return d.current >= len(d.source)
+}
+
+// Look at next byte
+func (d *JsonDecoder) peek() byte {
+	if d.isAtEnd() {
+		return byte(0)
+	}
+	return d.source[d.current]
+}
+
+// Move forward and return the passed byte, also noting line breaks
+func (d *JsonDecoder) advance() byte {
+	c := d.peek()
+	d.current++
+	if c == '\n' {
+		d.line++
+		d.colStart = d.current
+	}
+	return c
+}
+
+// Advance only for a given byte
+func (d *JsonDecoder) match(expected byte) bool {
+	if d.isAtEnd() || d.peek() != expected {
+		return false
+	}
+	d.advance()
+	return true
+}
+
+// Consume a given byte, or throw an error
+func (d *JsonDecoder) expect(expected byte, message string) {
+	if !d.match(expected) {
+		d.error(message)
+	}
+}
+
+// Throw a runtime error, noting the line and column of the JSON source
+func (d *JsonDecoder) error(message string) {
+	panic(RuntimeError{message: "Invalid JSON [line " + strconv.Itoa(d.line) +
+		", col " + strconv.Itoa(d.current-d.colStart+1) + "]: " + message})
+}
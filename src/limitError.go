@@ -0,0 +1,21 @@
+// Ward Jaeger, CS 403
+package main
+
+// Kinds of limits that a sandboxed run can exceed
+type limitKind string
+
+const (
+	STEP_LIMIT  limitKind = "step"
+	TIME_LIMIT  limitKind = "time"
+	DEPTH_LIMIT limitKind = "depth"
+	ALLOC_LIMIT limitKind = "allocation"
+	TASK_LIMIT  limitKind = "task"
+)
+
+// Struct to indicate that a run exceeded one of its limits
+// Unlike a RuntimeError, it always ends the run, so the host can tell which limit was exceeded
+type LimitError struct {
+	kind    limitKind
+	token   Token // Empty if the error is not tied to a particular token
+	message string
+}
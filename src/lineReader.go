@@ -0,0 +1,112 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Buffered reader for standard input and file handles, whose reads run on their own goroutine
+// so that a sandboxed run can cut them short
+// A read that is cut short keeps going, and whatever it reads is handed to the next read instead of being lost
+type lineReader struct {
+	lock    sync.Mutex
+	reader  *bufio.Reader
+	unread  string        // Text that was read but not yet returned
+	atEOF   bool          // Whether the last read reached the end of the input
+	err     error         // Error from the last read, to be thrown by the next read that returns
+	reading chan struct{} // Closed once the read in progress finishes, or nil if there isn't one
+}
+
+// Build a line reader for the given input
+func newLineReader(reader io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReader(reader)}
+}
+
+// Read the next line (without the line break), or nil at EOF
+// A nil sandbox waits for as long as the read takes
+func (r *lineReader) readLine(s *sandbox) any {
+	line, eof := r.read(s, false)
+	if eof && line == "" {
+		return nil
+	}
+	return newString(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+}
+
+// Read all of the remaining input as a string
+func (r *lineReader) readAll(s *sandbox) any {
+	text, _ := r.read(s, true)
+	return newString(text)
+}
+
+// Take either a line (with its line break) or all of the remaining input, returning whether EOF was reached
+func (r *lineReader) read(s *sandbox, all bool) (string, bool) {
+	r.lock.Lock()
+	for {
+		if r.err != nil {
+			err := r.err
+			r.err = nil
+			r.lock.Unlock()
+			panic(RuntimeError{message: err.Error()})
+		}
+		if end := strings.IndexByte(r.unread, '\n'); end >= 0 && !all {
+			line := r.unread[:end+1]
+			r.unread = r.unread[end+1:]
+			r.lock.Unlock()
+			return line, false
+		}
+		if r.atEOF {
+			text := r.unread
+			r.unread, r.atEOF = "", false
+			r.lock.Unlock()
+			return text, true
+		}
+
+		// Wait on a read in progress (which may have been started by an earlier call that was cut short)
+		if r.reading == nil {
+			r.reading = make(chan struct{})
+			go r.fill(all, r.reading)
+		}
+		reading := r.reading
+		r.lock.Unlock()
+		if s == nil {
+			<-reading
+		} else {
+			select {
+			case <-reading:
+			case <-s.cancelled():
+				s.interrupted()
+			}
+		}
+		r.lock.Lock()
+	}
+}
+
+// Body of a read's goroutine, which adds what it reads to the unread text
+func (r *lineReader) fill(all bool, done chan struct{}) {
+	var text string
+	var err error
+	if all {
+		var contents []byte
+		contents, err = io.ReadAll(r.reader)
+		text = string(contents)
+		if err == nil {
+			err = io.EOF
+		}
+	} else {
+		text, err = r.reader.ReadString('\n')
+	}
+
+	r.lock.Lock()
+	r.unread += text
+	if err == io.EOF {
+		r.atEOF = true
+	} else if err != nil {
+		r.err = err
+	}
+	r.reading = nil
+	r.lock.Unlock()
+	close(done)
+}
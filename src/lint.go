@@ -0,0 +1,790 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Every rule the linter checks, in the order they are documented
+var lintRules = []string{
+	"unused-variable",
+	"unused-parameter",
+	"shadowed-name",
+	"unreachable-code",
+	"assignment-in-condition",
+	"nan-comparison",
+	"wrong-arity",
+	"undefined-global",
+	"constant-condition",
+}
+
+// Comment that disables warnings on its line, or on the next line if it is alone on its line
+const lintIgnore = "wixme:ignore"
+
+// A suspicious piece of code found by the linter
+type lintWarning struct {
+	rule    string
+	token   Token // Empty if the warning is only tied to a line
+	line    int
+	message string
+}
+
+// A variable declared in a scope, tracked until the scope ends
+type lintVariable struct {
+	name     Token
+	rule     string      // Rule for the variable going unused, or empty if it may go unused
+	used     bool        // Whether it is ever read
+	assigned bool        // Whether it is ever reassigned, after which what it holds is unknown
+	callable Callable    // Function or class it is declared as, or nil
+	calls    []*CallExpr // Calls to it, which are checked against its arity once it is known not to be reassigned
+}
+
+// Visitor pattern that walks a resolved script and collects warnings about suspicious code
+type Linter struct {
+	scopes   []map[string]*lintVariable // The first scope holds the globals
+	natives  map[string]any
+	lines    map[Stmt]int
+	warnings []lintWarning
+}
+
+// Test for interface implementation
+var _ ExprVisitor = &Linter{}
+var _ StmtVisitor = &Linter{}
+
+// Build a linter for a script whose statements are on the given lines
+func newLinter(lines map[Stmt]int) *Linter {
+	natives := &Interpreter{}
+	setUpInterpreter(natives, Limits{})
+	return &Linter{natives: natives.globals.values, lines: lines}
+}
+
+// Entry point for linting, which declares every global first, since functions may use globals declared below them
+func (l *Linter) lint(statements []Stmt) []lintWarning {
+	l.beginScope()
+	for _, statement := range statements {
+		switch stmt := statement.(type) {
+		case *ClassStmt:
+			l.declare(stmt.name, "", classCallable(stmt))
+		case *FunctionStmt:
+			l.declare(stmt.name, "", functionCallable(stmt))
+		case *TraitStmt:
+			l.declare(stmt.name, "", nil)
+		case *VarStmt:
+			for _, name := range varNames(stmt) {
+				l.declare(name, "", nil)
+			}
+		}
+	}
+	l.lintStatements(statements)
+	l.endScope()
+
+	sort.SliceStable(l.warnings, func(a, b int) bool {
+		if l.warnings[a].line != l.warnings[b].line {
+			return l.warnings[a].line < l.warnings[b].line
+		}
+		return l.warnings[a].token.col < l.warnings[b].token.col
+	})
+	return l.warnings
+}
+
+// A class that can be called with a known arity, or nil if a trait may give it its initializer
+func classCallable(stmt *ClassStmt) Callable {
+	if len(stmt.traits) > 0 {
+		return nil
+	}
+	class := &Class{name: stmt.name.lexeme, declaration: stmt, methods: map[string]*Function{}}
+	for _, method := range stmt.methods {
+		if method.name.lexeme == "init" {
+			class.methods["init"] = &Function{declaration: method}
+		}
+	}
+	return class
+}
+
+// Variables declared by a variable statement
+func varNames(stmt *VarStmt) []Token {
+	if stmt.pattern != nil {
+		return stmt.pattern.bindings()
+	}
+	return []Token{stmt.name}
+}
+
+// Note a warning at a token
+func (l *Linter) warn(rule string, token Token, message string) {
+	l.warnings = append(l.warnings, lintWarning{rule: rule, token: token, line: token.line, message: message})
+}
+
+// Note a warning at a statement, which has no token of its own
+func (l *Linter) warnLine(rule string, stmt Stmt, message string) {
+	if line, ok := l.lines[stmt]; ok {
+		l.warnings = append(l.warnings, lintWarning{rule: rule, line: line, message: message})
+	}
+}
+
+// Pass linter to statements and expressions
+func (l *Linter) lintStmt(stmt Stmt) {
+	stmt.accept(l)
+}
+func (l *Linter) lintExpr(expr Expr) {
+	expr.accept(l)
+}
+
+// Lint a list of statements, noting any that follow a return
+func (l *Linter) lintStatements(statements []Stmt) {
+	for j, statement := range statements {
+		if j > 0 {
+			if _, ok := statements[j-1].(*ReturnStmt); ok {
+				l.warnLine("unreachable-code", statement, "Unreachable code after return.")
+			}
+		}
+		l.lintStmt(statement)
+	}
+}
+
+// Creates an additional scope one level deeper
+func (l *Linter) beginScope() {
+	l.scopes = append(l.scopes, map[string]*lintVariable{})
+}
+
+// Removes the most recent scope, noting variables that were never used,
+// and calls to functions that were never reassigned that have the wrong number of arguments
+func (l *Linter) endScope() {
+	scope := l.scopes[len(l.scopes)-1]
+	l.scopes = l.scopes[:len(l.scopes)-1]
+
+	for _, variable := range scope {
+		if !variable.used && variable.rule != "" && !strings.HasPrefix(variable.name.lexeme, "_") {
+			kind := "Variable"
+			if variable.rule == "unused-parameter" {
+				kind = "Parameter"
+			}
+			l.warn(variable.rule, variable.name, kind+" '"+variable.name.lexeme+"' is never used.")
+		}
+		if !variable.assigned {
+			for _, call := range variable.calls {
+				l.checkArity(variable.callable, call)
+			}
+		}
+	}
+}
+
+// Declare a variable in the current scope, noting if it hides one in an enclosing scope
+// Globals are declared before linting begins, so they are not declared again
+func (l *Linter) declare(name Token, rule string, callable Callable) {
+	scope := l.scopes[len(l.scopes)-1]
+	if len(l.scopes) == 1 {
+		if _, found := scope[name.lexeme]; found {
+			return
+		}
+	} else if name.lexeme != "_" {
+		if _, found := l.lookup(name.lexeme); found {
+			l.warn("shadowed-name", name, "Variable '"+name.lexeme+"' shadows a variable in an enclosing scope.")
+		} else if _, found := l.natives[name.lexeme]; found {
+			l.warn("shadowed-name", name, "Variable '"+name.lexeme+"' shadows a native.")
+		}
+	}
+	scope[name.lexeme] = &lintVariable{name: name, rule: rule, callable: callable}
+}
+
+// Find the innermost variable with a given name
+func (l *Linter) lookup(name string) (*lintVariable, bool) {
+	for j := len(l.scopes) - 1; j >= 0; j-- {
+		if variable, found := l.scopes[j][name]; found {
+			return variable, true
+		}
+	}
+	return nil, false
+}
+
+// Note a variable being read or assigned, which must be declared somewhere or be a native
+func (l *Linter) reference(name Token, isAssigned bool) *lintVariable {
+	variable, found := l.lookup(name.lexeme)
+	if !found {
+		if _, found := l.natives[name.lexeme]; !found {
+			l.warn("undefined-global", name, "Undefined variable '"+name.lexeme+"'.")
+		}
+		return nil
+	}
+	if isAssigned {
+		variable.assigned = true
+	} else {
+		variable.used = true
+	}
+	return variable
+}
+
+// A function that can be called with a known arity, or nil if a decorator may replace it with anything
+func functionCallable(stmt *FunctionStmt) Callable {
+	if len(stmt.decorators) > 0 {
+		return nil
+	}
+	return &Function{declaration: stmt}
+}
+
+// Lints the decorators, then declares the parameters in a new scope, and lints the body
+func (l *Linter) lintFunction(function *FunctionStmt, hasBody bool) {
+	for _, decorator := range function.decorators {
+		l.lintExpr(decorator)
+	}
+	l.beginScope()
+	for _, param := range function.params {
+		if param.defaultValue != nil {
+			l.lintExpr(param.defaultValue)
+		}
+		rule := "unused-parameter"
+		if !hasBody {
+			rule = ""
+		}
+		l.declare(param.name, rule, nil)
+	}
+	l.lintStatements(function.body)
+	l.endScope()
+}
+
+// Check a condition for an assignment where a comparison was likely meant, and for a value that never changes
+func (l *Linter) lintCondition(condition Expr, token Token, stmt Stmt) {
+	switch expr := condition.(type) {
+	case *AssignExpr:
+		l.warn("assignment-in-condition", expr.name, "Assignment in condition.")
+	case *SetExpr:
+		l.warn("assignment-in-condition", expr.name, "Assignment in condition.")
+	case *ReplaceExpr:
+		l.warn("assignment-in-condition", expr.bracket, "Assignment in condition.")
+	}
+
+	// A loop on the literal true is the usual way to loop until a return
+	if literal, ok := condition.(*LiteralExpr); ok && literal.value == true {
+		if _, ok := stmt.(*WhileStmt); ok {
+			return
+		}
+	}
+	if value, ok := constantValue(condition); ok {
+		message := fmt.Sprintf("Condition is always %t.", isTruthy(value))
+		if stmt != nil {
+			l.warnLine("constant-condition", stmt, message)
+		} else {
+			l.warn("constant-condition", token, message)
+		}
+	}
+	l.lintExpr(condition)
+}
+
+// Check that a call has an acceptable number of arguments
+func (l *Linter) checkArity(callable Callable, call *CallExpr) {
+	for _, argument := range call.arguments {
+		if _, ok := argument.(*SpreadExpr); ok {
+			return
+		}
+	}
+	min, max := callable.arity()
+	count := len(call.arguments)
+	if max != variadic && count > max {
+		l.warn("wrong-arity", call.paren, arityMessage(callable, count))
+		return
+	}
+	// Keyword arguments fill parameters of functions, but natives take them separately
+	if _, ok := callable.(*Native); !ok {
+		count += len(call.keywords)
+	}
+	if count < min {
+		l.warn("wrong-arity", call.paren, arityMessage(callable, count))
+	}
+}
+
+// Value of an expression that can be computed without running the script, if it has one
+func constantValue(expr Expr) (any, bool) {
+	switch expr := expr.(type) {
+	case *LiteralExpr:
+		return expr.value, true
+	case *GroupingExpr:
+		return constantValue(expr.expression)
+	case *UnaryExpr:
+		operand, ok := constantValue(expr.operand)
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case expr.operator.tokenType == BANG:
+			return !isTruthy(operand), true
+		case expr.operator.tokenType == MINUS && isNumber(operand):
+			return negate(operand), true
+		}
+	case *LogicalExpr:
+		left, ok := constantValue(expr.left)
+		if !ok {
+			return nil, false
+		}
+		if isTruthy(left) == (expr.operator.tokenType == OR) {
+			return left, true
+		}
+		return constantValue(expr.right)
+	case *BinaryExpr:
+		left, leftOk := constantValue(expr.left)
+		right, rightOk := constantValue(expr.right)
+		if !leftOk || !rightOk {
+			return nil, false
+		}
+		if !isNumber(left) || !isNumber(right) {
+			// Only equality of simple values is folded, which can't be overloaded
+			_, leftSequence := left.(Sequence)
+			_, rightSequence := right.(Sequence)
+			if leftSequence || rightSequence {
+				return nil, false
+			}
+			switch expr.operator.tokenType {
+			case EQUAL_EQUAL:
+				return left == right, true
+			case BANG_EQUAL:
+				return left != right, true
+			}
+			return nil, false
+		}
+		switch expr.operator.tokenType {
+		case PLUS, MINUS, STAR, SLASH:
+			return arithmetic(expr.operator.tokenType, left, right)
+		case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, EQUAL_EQUAL, BANG_EQUAL:
+			order, ok := compareNumbers(left, right)
+			switch expr.operator.tokenType {
+			case GREATER:
+				return ok && order > 0, true
+			case GREATER_EQUAL:
+				return ok && order >= 0, true
+			case LESS:
+				return ok && order < 0, true
+			case LESS_EQUAL:
+				return ok && order <= 0, true
+			case EQUAL_EQUAL:
+				return ok && order == 0, true
+			case BANG_EQUAL:
+				return !ok || order != 0, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Check whether an expression is always NaN
+func isConstantNaN(expr Expr) bool {
+	value, ok := constantValue(expr)
+	number, isFloat := value.(float64)
+	return ok && isFloat && math.IsNaN(number)
+}
+
+// Lint the statements in a new scope
+func (l *Linter) visitBlockStmt(stmt *BlockStmt) any {
+	l.beginScope()
+	l.lintStatements(stmt.statements)
+	l.endScope()
+	return nil
+}
+
+// Declares the class, and lints its members
+func (l *Linter) visitClassStmt(stmt *ClassStmt) any {
+	l.declare(stmt.name, "unused-variable", classCallable(stmt))
+	for _, trait := range stmt.traits {
+		l.lintExpr(trait)
+	}
+	for _, field := range stmt.staticFields {
+		if field.initializer != nil {
+			l.lintExpr(field.initializer)
+		}
+	}
+	for _, members := range [][]*FunctionStmt{stmt.staticMethods, stmt.methods, stmt.setters} {
+		for _, method := range members {
+			l.lintFunction(method, true)
+		}
+	}
+	return nil
+}
+
+// Lints the expression
+func (l *Linter) visitExpressionStmt(stmt *ExpressionStmt) any {
+	l.lintExpr(stmt.expression)
+	return nil
+}
+
+// Lints the iterable, then the body in a new scope with the loop variables
+func (l *Linter) visitForInStmt(stmt *ForInStmt) any {
+	l.lintExpr(stmt.iterable)
+
+	names := []Token{stmt.name}
+	if stmt.pattern != nil {
+		names = stmt.pattern.bindings()
+		l.lintPattern(stmt.pattern)
+	}
+
+	l.beginScope()
+	for _, name := range names {
+		l.declare(name, "unused-variable", nil)
+	}
+	l.lintStmt(stmt.body)
+	l.endScope()
+	return nil
+}
+
+// Declares and lints the function
+func (l *Linter) visitFunctionStmt(stmt *FunctionStmt) any {
+	l.declare(stmt.name, "unused-variable", functionCallable(stmt))
+	l.lintFunction(stmt, true)
+	return nil
+}
+
+// Lints the condition and branches
+func (l *Linter) visitIfStmt(stmt *IfStmt) any {
+	l.lintCondition(stmt.condition, Token{}, stmt)
+	l.lintStmt(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		l.lintStmt(stmt.elseBranch)
+	}
+	return nil
+}
+
+// Lints the return value
+func (l *Linter) visitReturnStmt(stmt *ReturnStmt) any {
+	if stmt.value != nil {
+		l.lintExpr(stmt.value)
+	}
+	return nil
+}
+
+// Declares the trait, and lints the default methods
+// Required methods have no bodies, so their parameters are never used
+func (l *Linter) visitTraitStmt(stmt *TraitStmt) any {
+	l.declare(stmt.name, "unused-variable", nil)
+	for _, method := range stmt.required {
+		l.lintFunction(method, false)
+	}
+	for _, method := range stmt.methods {
+		l.lintFunction(method, true)
+	}
+	return nil
+}
+
+// Lints the value, then declares (every variable of a pattern), so the value can't refer to the new variables
+func (l *Linter) visitVarStmt(stmt *VarStmt) any {
+	if stmt.pattern != nil {
+		l.lintPattern(stmt.pattern)
+	}
+	if stmt.initializer != nil {
+		l.lintExpr(stmt.initializer)
+	}
+	for _, name := range varNames(stmt) {
+		l.declare(name, "unused-variable", nil)
+	}
+	return nil
+}
+
+// Lints the condition and the body
+func (l *Linter) visitWhileStmt(stmt *WhileStmt) any {
+	l.lintCondition(stmt.condition, Token{}, stmt)
+	l.lintStmt(stmt.body)
+	return nil
+}
+
+// Lints the value, then notes the variable as reassigned
+func (l *Linter) visitAssignExpr(expr *AssignExpr) any {
+	l.lintExpr(expr.value)
+	l.reference(expr.name, true)
+	return nil
+}
+
+// Lints both operands, noting comparisons with NaN, which are never true
+func (l *Linter) visitBinaryExpr(expr *BinaryExpr) any {
+	switch expr.operator.tokenType {
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, EQUAL_EQUAL, BANG_EQUAL:
+		if isConstantNaN(expr.left) || isConstantNaN(expr.right) {
+			l.warn("nan-comparison", expr.operator, "Comparison with NaN is never true, except with '!='.")
+		}
+	}
+	l.lintExpr(expr.left)
+	l.lintExpr(expr.right)
+	return nil
+}
+
+// Lints the callee and the arguments, noting calls to known functions to check their arity
+func (l *Linter) visitCallExpr(expr *CallExpr) any {
+	if callee, ok := expr.callee.(*VariableExpr); ok {
+		if variable := l.reference(callee.Token, false); variable != nil {
+			if variable.callable != nil {
+				variable.calls = append(variable.calls, expr)
+			}
+		} else if native, ok := l.natives[callee.lexeme].(*Native); ok {
+			l.checkArity(native, expr)
+		}
+	} else {
+		l.lintExpr(expr.callee)
+	}
+
+	for _, argument := range expr.arguments {
+		l.lintExpr(argument)
+	}
+	for _, keyword := range expr.keywords {
+		l.lintExpr(keyword.value)
+	}
+	return nil
+}
+
+// Lints the chain
+func (l *Linter) visitChainExpr(expr *ChainExpr) any {
+	l.lintExpr(expr.expression)
+	return nil
+}
+
+// Lints the value, then each target
+func (l *Linter) visitDestructureExpr(expr *DestructureExpr) any {
+	l.lintExpr(expr.value)
+	l.lintTargets(expr.targets)
+	return nil
+}
+
+// Lints destructuring targets, noting variables as reassigned without reading them
+func (l *Linter) lintTargets(targets []Expr) {
+	for _, target := range targets {
+		if spread, ok := target.(*SpreadExpr); ok {
+			target = spread.expression
+		}
+
+		switch target := target.(type) {
+		case *VariableExpr:
+			l.reference(target.Token, true)
+		case *GetExpr:
+			l.lintExpr(target.object)
+		case *IndexExpr:
+			l.lintExpr(target.indexee)
+			l.lintExpr(target.start)
+		case *ListExpr:
+			l.lintTargets(target.elements)
+		}
+	}
+}
+
+// Lints the object
+func (l *Linter) visitGetExpr(expr *GetExpr) any {
+	l.lintExpr(expr.object)
+	return nil
+}
+
+// Lints the expression
+func (l *Linter) visitGroupingExpr(expr *GroupingExpr) any {
+	l.lintExpr(expr.expression)
+	return nil
+}
+
+// Lints the indexee and the indices
+func (l *Linter) visitIndexExpr(expr *IndexExpr) any {
+	l.lintExpr(expr.indexee)
+	l.lintExpr(expr.start)
+	if expr.stop != nil {
+		l.lintExpr(expr.stop)
+	}
+	return nil
+}
+
+// Lints each element
+func (l *Linter) visitListExpr(expr *ListExpr) any {
+	for _, element := range expr.elements {
+		l.lintExpr(element)
+	}
+	return nil
+}
+
+// Does nothing, literals are never suspicious on their own
+func (*Linter) visitLiteralExpr(*LiteralExpr) any {
+	return nil
+}
+
+// Lints both operands
+func (l *Linter) visitLogicalExpr(expr *LogicalExpr) any {
+	l.lintExpr(expr.left)
+	l.lintExpr(expr.right)
+	return nil
+}
+
+// Lints the subject, then each arm in its own scope with its bindings
+func (l *Linter) visitMatchExpr(expr *MatchExpr) any {
+	l.lintExpr(expr.subject)
+
+	for _, arm := range expr.arms {
+		l.beginScope()
+		for _, name := range arm.bindings {
+			l.declare(name, "unused-variable", nil)
+		}
+		for _, pattern := range arm.patterns {
+			l.lintPattern(pattern)
+		}
+
+		if arm.guard != nil {
+			l.lintExpr(arm.guard)
+		}
+		if arm.body != nil {
+			l.lintStmt(arm.body)
+		} else {
+			l.lintExpr(arm.value)
+		}
+		l.endScope()
+	}
+	return nil
+}
+
+// Lints any expressions within a pattern (only the classes of class patterns)
+func (l *Linter) lintPattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *ListPattern:
+		for _, element := range pattern.elements {
+			l.lintPattern(element)
+		}
+	case *ClassPattern:
+		l.lintExpr(pattern.class)
+		for _, arg := range pattern.args {
+			l.lintPattern(arg)
+		}
+	case *ObjectPattern:
+		for _, field := range pattern.fields {
+			l.lintPattern(field.pattern)
+		}
+	}
+}
+
+// Lints indexee, index, and value
+func (l *Linter) visitReplaceExpr(expr *ReplaceExpr) any {
+	l.lintExpr(expr.indexee)
+	l.lintExpr(expr.index)
+	l.lintExpr(expr.value)
+	return nil
+}
+
+// Lints the object and the value
+func (l *Linter) visitSetExpr(expr *SetExpr) any {
+	l.lintExpr(expr.object)
+	l.lintExpr(expr.value)
+	return nil
+}
+
+// Lints the call
+func (l *Linter) visitSpawnExpr(expr *SpawnExpr) any {
+	l.lintExpr(expr.call)
+	return nil
+}
+
+// Lints the spread expression
+func (l *Linter) visitSpreadExpr(expr *SpreadExpr) any {
+	l.lintExpr(expr.expression)
+	return nil
+}
+
+// Lints the condition and both values
+func (l *Linter) visitTernaryExpr(expr *TernaryExpr) any {
+	l.lintCondition(expr.condition, expr.operator, nil)
+	l.lintExpr(expr.trueValue)
+	l.lintExpr(expr.falseValue)
+	return nil
+}
+
+// Does nothing, "this" is always defined where the resolver allows it
+func (*Linter) visitThisExpr(*ThisExpr) any {
+	return nil
+}
+
+// Lints operand
+func (l *Linter) visitUnaryExpr(expr *UnaryExpr) any {
+	l.lintExpr(expr.operand)
+	return nil
+}
+
+// Notes the variable as used
+func (l *Linter) visitVariableExpr(expr *VariableExpr) any {
+	l.reference(expr.Token, false)
+	return nil
+}
+
+// Lints the value
+func (l *Linter) visitYieldExpr(expr *YieldExpr) any {
+	if expr.value != nil {
+		l.lintExpr(expr.value)
+	}
+	return nil
+}
+
+// Read which rules are enabled from a JSON object of rule names to booleans
+// Every rule is enabled unless the config disables it
+func readLintConfig(path string, required bool) (enabled map[string]bool, err error) {
+	enabled = map[string]bool{}
+	for _, rule := range lintRules {
+		enabled[rule] = true
+	}
+	contents, readErr := os.ReadFile(path)
+	if readErr != nil {
+		if required {
+			return nil, fmt.Errorf("Could not open file %s", path)
+		}
+		return enabled, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeErr, ok := r.(RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			enabled, err = nil, fmt.Errorf("%s: %s", path, runtimeErr.message)
+		}
+	}()
+	decoder := JsonDecoder{source: string(contents), line: 1}
+	config, ok := decoder.decode().(*Instance)
+	if !ok {
+		return nil, fmt.Errorf("%s: Expect an object of rules.", path)
+	}
+	for rule, value := range config.fields {
+		on, ok := value.(bool)
+		if _, known := enabled[rule]; !known {
+			return nil, fmt.Errorf("%s: Unknown rule '%s'.", path, rule)
+		} else if !ok {
+			return nil, fmt.Errorf("%s: Expect true or false for rule '%s'.", path, rule)
+		}
+		enabled[rule] = on
+	}
+	return enabled, nil
+}
+
+// Find the rules ignored on each line by comments, where an empty list ignores every rule
+func lintIgnores(source string) map[int][]string {
+	ignores := map[int][]string{}
+	for j, text := range strings.Split(source, "\n") {
+		code, comment, found := strings.Cut(text, "//")
+		if !found || !strings.HasPrefix(strings.TrimSpace(comment), lintIgnore) {
+			continue
+		}
+		rules := strings.Fields(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(comment), lintIgnore), ",", " "))
+		line := j + 1
+		if strings.TrimSpace(code) == "" {
+			line++
+		}
+		ignores[line] = append(ignores[line], rules...)
+		if len(rules) == 0 {
+			ignores[line] = []string{}
+		}
+	}
+	return ignores
+}
+
+// Check whether a warning is disabled by the config or by a comment
+func (w lintWarning) ignored(enabled map[string]bool, ignores map[int][]string) bool {
+	if !enabled[w.rule] {
+		return true
+	}
+	rules, found := ignores[w.line]
+	return found && (len(rules) == 0 || containsString(rules, w.rule))
+}
+
+// Output a warning, noting the line and column if it has a token, and the rule that found it
+func (w lintWarning) String() string {
+	if w.token == (Token{}) {
+		return fmt.Sprintf("[line %d] Warning: %s (%s)", w.line, w.message, w.rule)
+	}
+	return fmt.Sprintf("[line %d, col %d] Warning at '%s': %s (%s)",
+		w.line, w.token.col, w.token.lexeme, w.message, w.rule)
+}
@@ -2,124 +2,316 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Boolean that keeps track of whether an error has occured anywhere, from parsing to interpretation
 var hadError = false
 
+// Kind of limit that the run exceeded, if any
+var exceededLimit limitKind
+
+// File to write the profile report to, if the run is profiled, and how many entries it lists
+var profilePath string
+var profileTop int
+
+// File to add coverage to, if coverage is recorded
+var coveragePath string
+
+// Whether to run the syntax tree exactly as it was parsed, without optimizing it first
+var noOptimize bool
+
 // Interpreter class that gets initialized throughout the main file
 var mainInterpreter = Interpreter{}
 
+// Buffered reader shared by everything that reads from stdin, so mixed reads don't lose data
+var stdinReader = newLineReader(os.Stdin)
+
+// Directory of the running script, which relative file paths are resolved against
+var scriptDir = "."
+
 // Entry point for the entire class
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: wixme [script]")
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		checkFlags := flag.NewFlagSet("check", flag.ExitOnError)
+		checkFlags.Usage = func() {
+			fmt.Fprintln(checkFlags.Output(), "Usage: wixme check script")
+		}
+		checkFlags.Parse(os.Args[2:])
+		if checkFlags.NArg() != 1 {
+			checkFlags.Usage()
+			os.Exit(1)
+		}
+		checkFile(checkFlags.Arg(0))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lintFlags := flag.NewFlagSet("lint", flag.ExitOnError)
+		config := lintFlags.String("config", "", "JSON file of rules to enable or disable (default wixmelint.json next to the script)")
+		lintFlags.Usage = func() {
+			fmt.Fprintln(lintFlags.Output(), "Usage: wixme lint [options] script")
+			lintFlags.PrintDefaults()
+		}
+		lintFlags.Parse(os.Args[2:])
+		if lintFlags.NArg() != 1 {
+			lintFlags.Usage()
+			os.Exit(1)
+		}
+		lintFile(lintFlags.Arg(0), *config)
+		return
+	}
+
+	limits := Limits{}
+	flag.Int64Var(&limits.maxSteps, "max-steps", 0, "maximum number of statements to execute")
+	flag.DurationVar(&limits.timeout, "timeout", 0, "maximum wall-clock time to run for (like 2s)")
+	flag.IntVar(&limits.maxDepth, "max-depth", 0, "maximum depth of nested function calls")
+	flag.Int64Var(&limits.maxAlloc, "max-alloc", 0, "maximum number of list and string elements to allocate")
+	flag.Int64Var(&limits.maxTasks, "max-tasks", 0, "maximum number of spawned tasks running at once")
+	flag.BoolVar(&limits.noIO, "no-io", false, "disable the file system library")
+	flag.StringVar(&profilePath, "profile", "", "write a profile report to a file, and folded call stacks next to it")
+	profileLines := flag.Bool("profile-lines", false, "also time each statement for the profile report")
+	flag.IntVar(&profileTop, "profile-top", 20, "number of functions and lines in the profile report")
+	trace := flag.Bool("trace", false, "log each function call with its arguments and return value")
+	traceFile := flag.String("trace-file", "", "write the trace to a file, rather than to standard error")
+	traceFilter := flag.String("trace-filter", "", "only trace functions whose names match a glob (like 'count*')")
+	traceStatements := flag.Bool("trace-statements", false, "also trace each statement with its line")
+	flag.BoolVar(&mainInterpreter.enforceTypes, "enforce-types", false, "check annotated parameters and return values at each call")
+	flag.StringVar(&coveragePath, "coverage", "", "add line and branch coverage to an lcov file, and annotate the source next to it")
+	flag.BoolVar(&noOptimize, "no-optimize", false, "run the script without folding constants and removing dead branches first")
+	showTokens := flag.Bool("tokens", false, "print the tokens of the script without running it")
+	showAst := flag.Bool("ast", false, "print the syntax tree of the script as S-expressions without running it")
+	showAstJson := flag.Bool("ast-json", false, "print the syntax tree of the script as JSON without running it")
+	checkOnly := flag.Bool("check", false, "scan, parse, and resolve the script without running it")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: wixme [options] [script]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		flag.Usage()
 		os.Exit(1)
+	} else if *showTokens || *showAst || *showAstJson || *checkOnly {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		inspectFile(flag.Arg(0), *showTokens, *showAst, *showAstJson)
 	} else {
-		setUpInterpreter(&mainInterpreter)
+		setUpInterpreter(&mainInterpreter, limits)
+		if profilePath != "" {
+			mainInterpreter.profiler = newProfiler(*profileLines)
+		}
+		if coveragePath != "" {
+			// The annotated source would overwrite an lcov file that already has its name
+			if coverageReportPath(coveragePath) == coveragePath {
+				fmt.Println("Coverage file can't have the extension .txt, which is used for the annotated source.")
+				os.Exit(1)
+			}
+			mainInterpreter.coverage = newCoverage()
+		}
+		if *trace || *traceFile != "" || *traceFilter != "" || *traceStatements {
+			mainInterpreter.tracer = &tracer{output: os.Stderr, filter: *traceFilter, statements: *traceStatements}
+			if *traceFile != "" {
+				file, err := os.Create(*traceFile)
+				if err != nil {
+					fmt.Println("Could not open file " + *traceFile)
+					os.Exit(1)
+				}
+				defer file.Close()
+				mainInterpreter.tracer.output = file
+			}
+		}
 
-		if len(os.Args) == 2 {
-			runFile(os.Args[1])
+		if flag.NArg() == 1 {
+			runFile(flag.Arg(0))
 		} else {
 			runPrompt()
 		}
 	}
 }
 
-// Sets up the interpreter with fresh environments and native functions
-func setUpInterpreter(interpreter *Interpreter) {
+// Sets up the interpreter with fresh environments and native functions, running within the given limits
+func setUpInterpreter(interpreter *Interpreter, limits Limits) {
+	interpreter.sandbox = newSandbox(limits)
+	interpreter.failedTasks = &taskFailures{}
 	interpreter.environment = &Environment{values: map[string]any{}}
 	interpreter.globals = interpreter.environment
 
 	interpreter.globals.define("clock", &Native{
-		arityFunc: func() int { return 0 },
+		arityFunc: func() (int, int) { return 0, 0 },
 		callFunc: func(_ *Interpreter, _ []any) any {
 			return float64(time.Now().UnixNano()) / 1000000000
 		},
 	})
 	interpreter.globals.define("len", &Native{
-		arityFunc: func() int { return 1 },
-		callFunc: func(_ *Interpreter, args []any) any {
+		arityFunc: func() (int, int) { return 1, 1 },
+		callFunc: func(interpreter *Interpreter, args []any) any {
 			if sequence, ok := args[0].(Sequence); ok {
-				return float64(len(sequence.list))
+				return int64(len(sequence.list))
+			}
+			if length, ok := interpreter.callSpecial(args[0], "__len__", interpreter.nativeCall); ok {
+				return length
 			}
 			panic(RuntimeError{message: "Expect string or list."})
 		},
 	})
 	interpreter.globals.define("print", &Native{
-		arityFunc: func() int { return 1 },
-		callFunc: func(_ *Interpreter, args []any) any {
-			fmt.Println(stringify(args[0], false))
+		arityFunc: func() (int, int) { return 0, variadic },
+		keywords:  []string{"sep", "end"},
+		callFunc: func(interpreter *Interpreter, args []any) any {
+			values, sep, end := args[:len(args)-2], " ", "\n"
+			if args[len(args)-2] != nil {
+				sep = expectString(args[len(args)-2])
+			}
+			if args[len(args)-1] != nil {
+				end = expectString(args[len(args)-1])
+			}
+
+			strs := []string{}
+			for _, value := range values {
+				strs = append(strs, interpreter.stringify(value, false, interpreter.nativeCall))
+			}
+			fmt.Print(strings.Join(strs, sep) + end)
 			return nil
 		},
 	})
+	interpreter.globals.define("input", &Native{
+		allocates: true,
+		arityFunc: func() (int, int) { return 0, 1 },
+		callFunc: func(interpreter *Interpreter, args []any) any {
+			if len(args) == 1 {
+				fmt.Print(interpreter.stringify(args[0], false, interpreter.nativeCall))
+			}
+			return stdinReader.readLine(interpreter.sandbox)
+		},
+	})
+	interpreter.globals.define("readLine", &Native{
+		allocates: true,
+		arityFunc: func() (int, int) { return 0, 0 },
+		callFunc: func(interpreter *Interpreter, _ []any) any {
+			return stdinReader.readLine(interpreter.sandbox)
+		},
+	})
+	interpreter.globals.define("readAll", &Native{
+		allocates: true,
+		arityFunc: func() (int, int) { return 0, 0 },
+		callFunc: func(interpreter *Interpreter, _ []any) any {
+			return stdinReader.readAll(interpreter.sandbox)
+		},
+	})
+	interpreter.globals.define("stdin", newNativeInstance("stdin", map[string]any{
+		"next": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 0, 0 },
+			callFunc: func(interpreter *Interpreter, _ []any) any {
+				return stdinReader.readLine(interpreter.sandbox)
+			},
+		},
+	}))
 	interpreter.globals.define("toNumber", &Native{
-		arityFunc: func() int { return 1 },
+		arityFunc: func() (int, int) { return 1, 1 },
 		callFunc: func(_ *Interpreter, args []any) any {
 			if sequence, ok := args[0].(Sequence); ok && sequence.isString {
-				str := stringify(sequence, false)
-				i := 0
-
-				// Throw error if conversion fails for any reason
-				defer func() {
-					if r := recover(); r != nil {
-						panic(RuntimeError{message: "Invalid format."})
-					}
-				}()
-
-				// Check for sign
-				sign := 1.0
-				if str[0] == '+' {
-					i++
-				} else if str[0] == '-' {
-					sign = -1.0
-					i++
+				// Accept the same formats as number literals, with an optional sign
+				str := sequence.toGoString()
+				negative := strings.HasPrefix(str, "-")
+				if negative || strings.HasPrefix(str, "+") {
+					str = str[1:]
 				}
-
-				// Check for whole part
-				if !isDigit(str[i]) {
-					panic(0)
+				value := parseNumber(str)
+				if value == nil {
+					panic(RuntimeError{message: "Invalid format."})
 				}
-				i++
-
-				// Loop through rest of string
-				fraction := false
-				for i < len(str) {
-					if !fraction && str[i] == '.' {
-						fraction = true
-						i++
-					}
-					if !isDigit(str[i]) {
-						panic(0)
-					}
-					i++
+				if negative {
+					return negate(value)
 				}
-
-				value, _ := strconv.ParseFloat(str, 64)
-				return value * sign
+				return value
 			}
 			panic(RuntimeError{message: "Expect string."})
 		},
 	})
+	interpreter.globals.define("float", &Native{
+		arityFunc: func() (int, int) { return 1, 1 },
+		callFunc: func(_ *Interpreter, args []any) any {
+			return convertNumber(args[0], false)
+		},
+	})
+	interpreter.globals.define("int", &Native{
+		arityFunc: func() (int, int) { return 1, 1 },
+		callFunc: func(_ *Interpreter, args []any) any {
+			return convertNumber(args[0], true)
+		},
+	})
 	interpreter.globals.define("toString", &Native{
-		arityFunc: func() int { return 1 },
+		allocates: true,
+		arityFunc: func() (int, int) { return 1, 1 },
+		callFunc: func(interpreter *Interpreter, args []any) any {
+			return newString(interpreter.stringify(args[0], false, interpreter.nativeCall))
+		},
+	})
+
+	interpreter.globals.define("implements", &Native{
+		arityFunc: func() (int, int) { return 2, 2 },
+		callFunc: func(_ *Interpreter, args []any) any {
+			if trait, ok := args[1].(*Trait); ok {
+				return trait.isImplementedBy(args[0])
+			}
+			panic(RuntimeError{message: "Expect trait."})
+		},
+	})
+	interpreter.globals.define("sleep", &Native{
+		arityFunc: func() (int, int) { return 1, 1 },
+		callFunc: func(interpreter *Interpreter, args []any) any {
+			if isNumber(args[0]) {
+				interpreter.sandbox.sleep(time.Duration(toFloat(args[0]) * float64(time.Second)))
+				return nil
+			}
+			panic(RuntimeError{message: "Expect number."})
+		},
+	})
+	interpreter.globals.define("chan", &Native{
+		arityFunc: func() (int, int) { return 0, 1 },
 		callFunc: func(_ *Interpreter, args []any) any {
-			stringified := stringify(args[0], false)
-			str := []any{}
-			for i := 0; i < len(stringified); i++ {
-				str = append(str, stringified[i])
+			if len(args) == 0 {
+				return newChannel(0)
 			}
-			return Sequence{list: str, isString: true}
+			if capacity, ok := toIndex(args[0]); ok && capacity >= 0 {
+				return newChannel(capacity)
+			}
+			panic(RuntimeError{message: "Capacity must be a non-negative number."})
+		},
+	})
+	interpreter.globals.define("select", &Native{
+		arityFunc: func() (int, int) { return 1, variadic },
+		keywords:  []string{"timeout"},
+		callFunc: func(interpreter *Interpreter, args []any) any {
+			return selectChannels(interpreter, args[:len(args)-1], args[len(args)-1])
 		},
 	})
+	if !limits.noIO {
+		interpreter.globals.define("fs", fsLibrary())
+	}
+	interpreter.globals.define("json", jsonLibrary())
+	for name, native := range reflectionNatives() {
+		interpreter.globals.define(name, native)
+	}
+	for name, native := range decoratorNatives() {
+		interpreter.globals.define(name, native)
+	}
+	nameNatives("", interpreter.globals.values)
 
 	interpreter.locals = map[Expr]int{}
+	interpreter.lines = map[Stmt]int{}
+	interpreter.owners = map[Expr]*ClassStmt{}
+	interpreter.constants = map[string]bool{}
 }
 
 // Run on the input from a given file
@@ -129,8 +321,121 @@ func runFile(filename string) {
 		fmt.Println("Could not open file " + filename)
 		os.Exit(1)
 	}
+	scriptDir = filepath.Dir(filename)
+	if mainInterpreter.profiler != nil {
+		mainInterpreter.profiler.source = strings.Split(string(src), "\n")
+	}
+	if mainInterpreter.tracer != nil {
+		mainInterpreter.tracer.source = strings.Split(string(src), "\n")
+	}
 	run(src)
 
+	if mainInterpreter.profiler != nil {
+		if err := mainInterpreter.profiler.write(profilePath, profileTop); err != nil {
+			fmt.Println("Could not write profile to " + profilePath)
+		}
+	}
+	if mainInterpreter.coverage != nil {
+		source, _ := filepath.Abs(filename)
+		if err := mainInterpreter.coverage.write(coveragePath, source); err != nil {
+			fmt.Println("Could not write coverage to " + coveragePath)
+		}
+	}
+
+	if exceededLimit != "" {
+		os.Exit(limitExitCodes[exceededLimit])
+	}
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+// Scan, parse, and resolve a file without running it, printing its tokens or syntax tree along the way
+func inspectFile(filename string, showTokens bool, showAst bool, showAstJson bool) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("Could not open file " + filename)
+		os.Exit(1)
+	}
+
+	scanner := Scanner{source: src, startChar: 0, currChar: 0, line: 1}
+	tokens := scanner.scanTokens()
+	if showTokens {
+		for _, token := range tokens {
+			fmt.Printf("%4d:%-4d %-24s %s\n", token.line, token.col, token.tokenType, token.lexeme)
+		}
+	}
+
+	statements := parseTokens(tokens)
+	dumper := AstDumper{lines: mainInterpreter.lines}
+	if showAst {
+		fmt.Print(writeSexpr(dumper.dump(statements)))
+	}
+	if showAstJson {
+		fmt.Print(writeAstJson(dumper.dump(statements)))
+	}
+}
+
+// Parse and resolve a script without running it, exiting with an error if there are any errors
+func parseFile(src []byte) []Stmt {
+	scanner := Scanner{source: src, startChar: 0, currChar: 0, line: 1}
+	return parseTokens(scanner.scanTokens())
+}
+
+// Parse and resolve the tokens of a script, exiting with an error if there are any errors
+func parseTokens(tokens []Token) []Stmt {
+	setUpInterpreter(&mainInterpreter, Limits{})
+	parser := Parser{tokens: tokens, current: 0, lines: mainInterpreter.lines}
+	statements := parser.parse()
+	if !hadError {
+		resolver := Resolver{interpreter: &mainInterpreter}
+		resolver.resolve(statements)
+	}
+	if hadError {
+		os.Exit(1)
+	}
+	return statements
+}
+
+// Check the types of a file without running it, exiting with an error if there are any mismatches
+func checkFile(filename string) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("Could not open file " + filename)
+		os.Exit(1)
+	}
+
+	newTypeChecker().check(parseFile(src))
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+// Check a file for suspicious code without running it, exiting with an error if there are any warnings
+func lintFile(filename string, configPath string) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("Could not open file " + filename)
+		os.Exit(1)
+	}
+	required := configPath != ""
+	if !required {
+		configPath = filepath.Join(filepath.Dir(filename), "wixmelint.json")
+	}
+	enabled, err := readLintConfig(configPath, required)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	statements := parseFile(src)
+	ignores := lintIgnores(string(src))
+	for _, warning := range newLinter(mainInterpreter.lines).lint(statements) {
+		if !warning.ignored(enabled, ignores) {
+			fmt.Println(warning)
+			hadError = true
+		}
+	}
 	if hadError {
 		os.Exit(1)
 	}
@@ -138,13 +443,16 @@ func runFile(filename string) {
 
 // Run in interactive mode from the terminal
 func runPrompt() {
-	stdin := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
 
-	for stdin.Scan() {
-		line := stdin.Bytes()
-		run(line)
+	for {
+		line := stdinReader.readLine(nil)
+		if line == nil {
+			return
+		}
+		run([]byte(expectString(line)))
 		hadError = false
+		exceededLimit = ""
 		fmt.Print("> ")
 	}
 }
@@ -153,7 +461,7 @@ func runPrompt() {
 func run(source []byte) {
 	scanner := Scanner{source: source, startChar: 0, currChar: 0, line: 1}
 	tokens := scanner.scanTokens()
-	parser := Parser{tokens: tokens, current: 0}
+	parser := Parser{tokens: tokens, current: 0, lines: mainInterpreter.lines}
 	statements := parser.parse()
 
 	// Stop if there was a syntax error.
@@ -169,6 +477,11 @@ func run(source []byte) {
 		return
 	}
 
+	// Dead branches would never be counted, so coverage is recorded on the tree as it was parsed
+	if !noOptimize && mainInterpreter.coverage == nil {
+		statements = newOptimizer().optimize(statements)
+	}
+
 	mainInterpreter.interpret(statements)
 }
 
@@ -191,6 +504,17 @@ func reportRuntime(err RuntimeError) {
 	report(err.token.line, err.token.col, " at '"+err.token.lexeme+"' during runtime", err.message)
 }
 
+// Report a given Limit error, which may not be tied to a token
+func reportLimit(err LimitError) {
+	exceededLimit = err.kind
+	if err.token == (Token{}) {
+		fmt.Println("Error during runtime: " + err.message)
+		hadError = true
+	} else {
+		report(err.token.line, err.token.col, " at '"+err.token.lexeme+"' during runtime", err.message)
+	}
+}
+
 // Output an error message, noting the line and column
 func report(line int, col int, where string, message string) {
 	fmt.Println("[line " + strconv.Itoa(line) + ", col " + strconv.Itoa(col) +
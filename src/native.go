@@ -3,53 +3,99 @@ package main
 
 import "fmt"
 
-// WIXME native functions, defined in main.go
+// WIXME native functions, defined in main.go (and library files like fs.go)
+// Keyword-only parameters are appended to the arguments in order, set to nil if omitted
 type Native struct {
-	arityFunc func() int
+	name      string // Set when the native is defined
+	arityFunc func() (int, int)
+	keywords  []string
 	callFunc  func(interpreter *Interpreter, arguments []any) any
+	allocates bool // Whether the native builds the list or string it returns, which counts toward the allocation limit
 }
 
+// Maximum arity of a callable that accepts any number of arguments
+const variadic = -1
+
 // Test for interface implementation
 var _ Callable = &Native{}
 
 func (n *Native) toString() string {
-	return "<native fn>"
+	return "<native fn " + n.name + ">"
 }
 
-func (n *Native) arity() int {
+func (n *Native) arity() (int, int) {
 	return n.arityFunc()
 }
 
 func (n *Native) call(interpreter *Interpreter, arguments []any) any {
-	return n.callFunc(interpreter, arguments)
+	if interpreter.profiler != nil {
+		interpreter.enterCall(n.name)
+		defer interpreter.exitCall()
+	}
+	result := n.callFunc(interpreter, arguments)
+	if n.allocates {
+		interpreter.sandbox.allocate(allocationSize(result), Token{})
+	}
+	return result
+}
+
+// Create an instance of an anonymous native class, exposing the given natives as fields
+// Used for libraries (like fs) and for native objects (like file handles)
+func newNativeInstance(className string, fields map[string]any) *Instance {
+	nameNatives(className+".", fields)
+	return &Instance{Class: &Class{name: className, methods: map[string]*Function{}},
+		fields: fields}
 }
 
-// Helper function for native function that converts objects into strings
+// Name any unnamed natives after the variables or fields they are stored in
+func nameNatives(prefix string, values map[string]any) {
+	for name, value := range values {
+		if native, ok := value.(*Native); ok && native.name == "" {
+			native.name = prefix + name
+		}
+	}
+}
+
+// Helper function for native functions that expect a string argument
+func expectString(value any) string {
+	if sequence, ok := value.(Sequence); ok && sequence.isString {
+		return sequence.toGoString()
+	}
+	panic(RuntimeError{message: "Expect string."})
+}
+
+// Convert objects into strings, using __str__ for Instances that overload it
 // Nested strings include quotes, isolated strings do not
-func stringify(value any, withQuotes bool) string {
+func (i *Interpreter) stringify(value any, withQuotes bool, token Token) string {
 	if value == nil {
 		return "nil"
+	} else if instance, ok := value.(*Instance); ok {
+		if result, ok := i.callSpecial(instance, "__str__", token); ok {
+			if str, ok := result.(Sequence); ok && str.isString {
+				return str.toGoString()
+			}
+			panic(RuntimeError{token: token, message: "__str__ must return a string."})
+		}
+		return instance.toString()
 	} else if callable, ok := value.(Callable); ok {
 		return callable.toString()
+	} else if trait, ok := value.(*Trait); ok {
+		return trait.toString()
 	} else if sequence, ok := value.(Sequence); ok {
 		// Sequences need to be recursively constructed
 		if sequence.isString {
-			runes := ""
-			for _, element := range sequence.list {
-				runes += string(element.(byte))
-			}
 			if withQuotes {
-				return "\"" + runes + "\""
+				return "\"" + sequence.toGoString() + "\""
 			} else {
-				return runes
+				return sequence.toGoString()
 			}
 		} else {
 			elements := ""
-			for j, element := range sequence.list {
+			for j, element := range sequence.elements() {
 				if j != 0 {
 					elements = elements + ", "
 				}
-				elements = elements + stringify(element, true)
+				elements = elements + i.stringify(element, true, token)
 			}
 			return "[" + elements + "]"
 		}
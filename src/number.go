@@ -0,0 +1,290 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numbers are either exact integers or floats
+// Integers are int64 values, which are promoted to *big.Int values when they overflow,
+// and *big.Int values are always outside of the range of int64
+
+// Check if a value is any kind of number
+func isNumber(value any) bool {
+	switch value.(type) {
+	case int64, *big.Int, float64:
+		return true
+	}
+	return false
+}
+
+// Check if a value is an integer
+func isInteger(value any) bool {
+	switch value.(type) {
+	case int64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// Convert a big integer back to an int64 if it fits
+func normalize(value *big.Int) any {
+	if value.IsInt64() {
+		return value.Int64()
+	}
+	return value
+}
+
+// Convert an integer to a big integer
+func toBig(value any) *big.Int {
+	if i, ok := value.(int64); ok {
+		return big.NewInt(i)
+	}
+	return value.(*big.Int)
+}
+
+// Convert any number to a float, which may lose precision
+func toFloat(value any) float64 {
+	switch value := value.(type) {
+	case int64:
+		return float64(value)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(value).Float64()
+		return f
+	}
+	return value.(float64)
+}
+
+// Convert a number to an index, truncating floats
+// Integers that are too big are clamped, so they are still out of range
+func toIndex(value any) (int, bool) {
+	switch value := value.(type) {
+	case int64:
+		return int(value), true
+	case *big.Int:
+		if value.Sign() < 0 {
+			return math.MinInt, true
+		}
+		return math.MaxInt, true
+	case float64:
+		if math.IsNaN(value) {
+			return 0, true
+		}
+		return int(math.Max(math.MinInt32, math.Min(math.MaxInt32, value))), true
+	}
+	return 0, false
+}
+
+// Perform an arithmetic operation on two numbers, if they are both numbers
+// Integers stay exact, except with "/", while anything involving a float is a float
+// Integer division by zero must be checked beforehand
+func arithmetic(operator tokenType, left any, right any) (any, bool) {
+	if !isNumber(left) || !isNumber(right) {
+		return nil, false
+	}
+
+	// Division is always on floats
+	if operator == SLASH {
+		return toFloat(left) / toFloat(right), true
+	}
+	if !isInteger(left) || !isInteger(right) {
+		l, r := toFloat(left), toFloat(right)
+		switch operator {
+		case PLUS:
+			return l + r, true
+		case MINUS:
+			return l - r, true
+		case STAR:
+			return l * r, true
+		case TILDE_SLASH:
+			return math.Floor(l / r), true
+		}
+	}
+
+	// Use int64 unless the operation overflows
+	l, lSmall := left.(int64)
+	r, rSmall := right.(int64)
+	if lSmall && rSmall {
+		switch operator {
+		case PLUS:
+			if sum := l + r; (r > 0) == (sum > l) || r == 0 {
+				return sum, true
+			}
+		case MINUS:
+			if difference := l - r; (r > 0) == (difference < l) || r == 0 {
+				return difference, true
+			}
+		case STAR:
+			if l == 0 || r == 0 {
+				return int64(0), true
+			}
+			if product := l * r; product/r == l && !(l == -1 && r == math.MinInt64) &&
+				!(r == -1 && l == math.MinInt64) {
+				return product, true
+			}
+		case TILDE_SLASH:
+			if !(l == math.MinInt64 && r == -1) {
+				// Round toward negative infinity, rather than toward zero
+				quotient := l / r
+				if l%r != 0 && (l < 0) != (r < 0) {
+					quotient--
+				}
+				return quotient, true
+			}
+		}
+	}
+
+	bl, br := toBig(left), toBig(right)
+	result := new(big.Int)
+	switch operator {
+	case PLUS:
+		result.Add(bl, br)
+	case MINUS:
+		result.Sub(bl, br)
+	case STAR:
+		result.Mul(bl, br)
+	case TILDE_SLASH:
+		modulus := new(big.Int)
+		result.QuoRem(bl, br, modulus)
+		if modulus.Sign() != 0 && (bl.Sign() < 0) != (br.Sign() < 0) {
+			result.Sub(result, big.NewInt(1))
+		}
+	}
+	return normalize(result), true
+}
+
+// Negate a number
+func negate(value any) any {
+	switch value := value.(type) {
+	case int64:
+		if value == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(value))
+		}
+		return -value
+	case *big.Int:
+		return normalize(new(big.Int).Neg(value))
+	}
+	return -value.(float64)
+}
+
+// Order two numbers, returning -1, 0, or 1
+// Returns false if the numbers can't be ordered, which is when either is NaN
+func compareNumbers(left any, right any) (int, bool) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if isInteger(left) && isInteger(right) {
+		return toBig(left).Cmp(toBig(right)), true
+	}
+
+	// Compare exactly, even when a big integer can't be converted to a float
+	toBigFloat := func(value any) (*big.Float, bool) {
+		if f, ok := value.(float64); ok {
+			if math.IsNaN(f) {
+				return nil, false
+			}
+			return new(big.Float).SetFloat64(f), true
+		}
+		return new(big.Float).SetInt(toBig(value)), true
+	}
+	l, lOk := toBigFloat(left)
+	r, rOk := toBigFloat(right)
+	if !lOk || !rOk {
+		return 0, false
+	}
+	return l.Cmp(r), true
+}
+
+// Convert an integer to a float, or convert a float to an integer by truncating it
+func convertNumber(value any, toInteger bool) any {
+	if !isNumber(value) {
+		panic(RuntimeError{message: "Expect number."})
+	}
+	if !toInteger {
+		return toFloat(value)
+	}
+	f, ok := value.(float64)
+	if !ok {
+		return value
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic(RuntimeError{message: "Can't convert NaN or Inf to an integer."})
+	}
+	result, _ := new(big.Float).SetFloat64(math.Trunc(f)).Int(nil)
+	return normalize(result)
+}
+
+// Parse a number literal, returning nil if it is invalid
+// Integers may be decimal, or hexadecimal, octal, or binary with a prefix of 0x, 0o, or 0b
+// Decimal numbers with a fractional part or exponent are floats
+// Underscores may separate digits
+func parseNumber(lexeme string) any {
+	digits, base := lexeme, 10
+	if len(lexeme) > 2 && lexeme[0] == '0' {
+		switch lexeme[1] {
+		case 'x', 'X':
+			digits, base = lexeme[2:], 16
+		case 'o', 'O':
+			digits, base = lexeme[2:], 8
+		case 'b', 'B':
+			digits, base = lexeme[2:], 2
+		}
+	}
+
+	// Underscores must be between two digits
+	isBaseDigit := isDigit
+	if base == 16 {
+		isBaseDigit = isHexDigit
+	}
+	for j := 0; j < len(digits); j++ {
+		if digits[j] == '_' && (j == 0 || j == len(digits)-1 ||
+			!isBaseDigit(digits[j-1]) || !isBaseDigit(digits[j+1])) {
+			return nil
+		}
+	}
+	digits = strings.ReplaceAll(digits, "_", "")
+	if digits == "" || !isBaseDigit(digits[0]) {
+		return nil
+	}
+
+	if base == 10 && strings.ContainsAny(digits, ".eE") {
+		// Go accepts more formats than WIXME, so the digits of a float are checked first
+		for j, c := range []byte(digits) {
+			if !isDigit(c) && c != '.' && c != 'e' && c != 'E' &&
+				!((c == '+' || c == '-') && (digits[j-1] == 'e' || digits[j-1] == 'E')) {
+				return nil
+			}
+		}
+		if dot := strings.IndexByte(digits, '.'); dot != -1 &&
+			(dot == len(digits)-1 || !isDigit(digits[dot+1])) {
+			return nil
+		}
+		value, err := strconv.ParseFloat(digits, 64)
+		if err != nil && !strings.Contains(err.Error(), "range") {
+			return nil
+		}
+		return value
+	}
+
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil
+	}
+	return normalize(value)
+}
+
+// Check if a byte is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
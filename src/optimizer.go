@@ -0,0 +1,516 @@
+// Ward Jaeger, CS 403
+package main
+
+// Visitor pattern that simplifies the syntax tree between resolving and interpreting
+// Each visit returns the node to use in place of the one visited, which is nil for a statement that is removed
+// Operations are only folded when they can't fail, so errors are still thrown at runtime
+type Optimizer struct {
+	scopes  []map[string]*LiteralExpr // Value of each constant that can be inlined, or nil for any other variable
+	globals map[string]*LiteralExpr
+}
+
+// Test for interface implementation
+var _ ExprVisitor = &Optimizer{}
+var _ StmtVisitor = &Optimizer{}
+
+// Create an optimizer for a new run, which only inlines the global constants declared during the run
+func newOptimizer() *Optimizer {
+	return &Optimizer{scopes: []map[string]*LiteralExpr{}, globals: map[string]*LiteralExpr{}}
+}
+
+// Entry point for optimization, leaving out statements that are removed
+func (o *Optimizer) optimize(statements []Stmt) []Stmt {
+	optimized := []Stmt{}
+	for _, statement := range statements {
+		if statement = o.optimizeStmt(statement); statement != nil {
+			optimized = append(optimized, statement)
+		}
+	}
+	return optimized
+}
+
+// Pass optimizer to statements and expressions
+func (o *Optimizer) optimizeStmt(stmt Stmt) Stmt {
+	if optimized := stmt.accept(o); optimized != nil {
+		return optimized.(Stmt)
+	}
+	return nil
+}
+func (o *Optimizer) optimizeExpr(expr Expr) Expr {
+	return expr.accept(o).(Expr)
+}
+
+// Optimize the body of a branch or loop, which must still be a statement when it is removed
+func (o *Optimizer) optimizeBody(stmt Stmt) Stmt {
+	if optimized := o.optimizeStmt(stmt); optimized != nil {
+		return optimized
+	}
+	return &BlockStmt{statements: []Stmt{}}
+}
+
+// Creates an additional scope one level deeper
+func (o *Optimizer) beginScope() {
+	o.scopes = append(o.scopes, map[string]*LiteralExpr{})
+}
+
+// Removes the most recent scope
+func (o *Optimizer) endScope() {
+	o.scopes = o.scopes[0 : len(o.scopes)-1]
+}
+
+// Note a variable in the current scope, with the value to inline it as, or nil if it can't be inlined
+func (o *Optimizer) declare(name Token, value *LiteralExpr) {
+	if length := len(o.scopes); length != 0 {
+		o.scopes[length-1][name.lexeme] = value
+	} else {
+		o.globals[name.lexeme] = value
+	}
+}
+
+// Find the value to inline a variable as, the same way the resolver finds its scope
+func (o *Optimizer) lookup(name Token) *LiteralExpr {
+	for i := len(o.scopes) - 1; i >= 0; i-- {
+		if value, found := o.scopes[i][name.lexeme]; found {
+			return value
+		}
+	}
+	return o.globals[name.lexeme]
+}
+
+// Optimizes the decorators, then the default values and the body in a new scope with the parameters
+func (o *Optimizer) optimizeFunction(function *FunctionStmt) {
+	o.optimizeExprs(function.decorators)
+	o.beginScope()
+	for j := range function.params {
+		o.declare(function.params[j].name, nil)
+		if function.params[j].defaultValue != nil {
+			function.params[j].defaultValue = o.optimizeExpr(function.params[j].defaultValue)
+		}
+	}
+	function.body = o.optimize(function.body)
+	o.endScope()
+}
+
+// Optimize every expression in a list, in place
+func (o *Optimizer) optimizeExprs(exprs []Expr) {
+	for j, expr := range exprs {
+		exprs[j] = o.optimizeExpr(expr)
+	}
+}
+
+// Value of an expression, if it is a literal
+func literalValue(expr Expr) (any, bool) {
+	if literal, ok := expr.(*LiteralExpr); ok {
+		return literal.value, true
+	}
+	return nil, false
+}
+
+// Check whether two literal values are equal, the same way the interpreter compares them
+func literalsEqual(left any, right any) bool {
+	if isNumber(left) && isNumber(right) {
+		order, ok := compareNumbers(left, right)
+		return ok && order == 0
+	}
+	l, leftSequence := left.(Sequence)
+	r, rightSequence := right.(Sequence)
+	if leftSequence || rightSequence {
+		return leftSequence && rightSequence && l.isString == r.isString && l.toGoString() == r.toGoString()
+	}
+	return left == right
+}
+
+// Perform a binary operation on two literal values, if it can't fail or be overloaded
+func foldBinary(operator tokenType, left any, right any) (any, bool) {
+	switch operator {
+	case EQUAL_EQUAL:
+		return literalsEqual(left, right), true
+	case BANG_EQUAL:
+		return !literalsEqual(left, right), true
+	}
+
+	if isNumber(left) && isNumber(right) {
+		switch operator {
+		case PLUS, MINUS, STAR, SLASH:
+			return arithmetic(operator, left, right)
+		case TILDE_SLASH:
+			// Integer division by zero is left to throw its error at runtime
+			if isInteger(left) && isInteger(right) && toBig(right).Sign() == 0 {
+				return nil, false
+			}
+			return arithmetic(operator, left, right)
+		case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+			// Comparisons with NaN are always false
+			order, ok := compareNumbers(left, right)
+			switch operator {
+			case GREATER:
+				return ok && order > 0, true
+			case GREATER_EQUAL:
+				return ok && order >= 0, true
+			case LESS:
+				return ok && order < 0, true
+			}
+			return ok && order <= 0, true
+		}
+		return nil, false
+	}
+
+	l, leftString := left.(Sequence)
+	r, rightString := right.(Sequence)
+	if operator == PLUS && leftString && rightString && l.isString && r.isString {
+		return newString(l.toGoString() + r.toGoString()), true
+	}
+	return nil, false
+}
+
+// Optimize the statements in the block's scope
+func (o *Optimizer) visitBlockStmt(stmt *BlockStmt) any {
+	o.beginScope()
+	stmt.statements = o.optimize(stmt.statements)
+	o.endScope()
+	return stmt
+}
+
+// Declares the class, and optimizes its members
+func (o *Optimizer) visitClassStmt(stmt *ClassStmt) any {
+	o.declare(stmt.name, nil)
+	for _, members := range [][]*FunctionStmt{stmt.staticMethods, stmt.methods, stmt.setters} {
+		for _, member := range members {
+			o.optimizeFunction(member)
+		}
+	}
+	for _, field := range stmt.staticFields {
+		if field.initializer != nil {
+			field.initializer = o.optimizeExpr(field.initializer)
+		}
+	}
+	return stmt
+}
+
+// Optimizes the expression
+func (o *Optimizer) visitExpressionStmt(stmt *ExpressionStmt) any {
+	stmt.expression = o.optimizeExpr(stmt.expression)
+	return stmt
+}
+
+// Optimizes the iterable, then the body in a new scope with the loop variables
+func (o *Optimizer) visitForInStmt(stmt *ForInStmt) any {
+	stmt.iterable = o.optimizeExpr(stmt.iterable)
+
+	names := []Token{stmt.name}
+	if stmt.pattern != nil {
+		names = stmt.pattern.bindings()
+	}
+
+	o.beginScope()
+	for _, name := range names {
+		o.declare(name, nil)
+	}
+	stmt.body = o.optimizeBody(stmt.body)
+	o.endScope()
+	return stmt
+}
+
+// Declares and optimizes the function
+func (o *Optimizer) visitFunctionStmt(stmt *FunctionStmt) any {
+	o.declare(stmt.name, nil)
+	o.optimizeFunction(stmt)
+	return stmt
+}
+
+// Replaces the statement with the branch that is taken, if the condition is a literal
+func (o *Optimizer) visitIfStmt(stmt *IfStmt) any {
+	stmt.condition = o.optimizeExpr(stmt.condition)
+	if condition, ok := literalValue(stmt.condition); ok {
+		if isTruthy(condition) {
+			return o.optimizeStmt(stmt.thenBranch)
+		} else if stmt.elseBranch != nil {
+			return o.optimizeStmt(stmt.elseBranch)
+		}
+		return nil
+	}
+
+	stmt.thenBranch = o.optimizeBody(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		stmt.elseBranch = o.optimizeStmt(stmt.elseBranch)
+	}
+	return stmt
+}
+
+// Optimizes the return value
+func (o *Optimizer) visitReturnStmt(stmt *ReturnStmt) any {
+	if stmt.value != nil {
+		stmt.value = o.optimizeExpr(stmt.value)
+	}
+	return stmt
+}
+
+// Declares the trait, and optimizes the default methods
+func (o *Optimizer) visitTraitStmt(stmt *TraitStmt) any {
+	o.declare(stmt.name, nil)
+	for _, method := range stmt.methods {
+		o.optimizeFunction(method)
+	}
+	return stmt
+}
+
+// Optimizes the value, then declares the variables
+// Constants of numbers, booleans, and nil are inlined, since they can't be changed through any other reference
+func (o *Optimizer) visitVarStmt(stmt *VarStmt) any {
+	if stmt.initializer != nil {
+		stmt.initializer = o.optimizeExpr(stmt.initializer)
+	}
+
+	if stmt.pattern != nil {
+		for _, name := range stmt.pattern.bindings() {
+			o.declare(name, nil)
+		}
+		return stmt
+	}
+
+	var inlined *LiteralExpr
+	if value, ok := literalValue(stmt.initializer); ok && stmt.constant {
+		if _, isBool := value.(bool); value == nil || isBool || isNumber(value) {
+			inlined = &LiteralExpr{value: value, token: stmt.name}
+		}
+	}
+	o.declare(stmt.name, inlined)
+	return stmt
+}
+
+// Removes the loop if the condition is always false, otherwise optimizes the body
+func (o *Optimizer) visitWhileStmt(stmt *WhileStmt) any {
+	stmt.condition = o.optimizeExpr(stmt.condition)
+	if condition, ok := literalValue(stmt.condition); ok && !isTruthy(condition) {
+		return nil
+	}
+
+	stmt.body = o.optimizeBody(stmt.body)
+	return stmt
+}
+
+// Optimizes the value
+func (o *Optimizer) visitAssignExpr(expr *AssignExpr) any {
+	expr.value = o.optimizeExpr(expr.value)
+	return expr
+}
+
+// Folds the operation if both operands are literals
+func (o *Optimizer) visitBinaryExpr(expr *BinaryExpr) any {
+	expr.left = o.optimizeExpr(expr.left)
+	expr.right = o.optimizeExpr(expr.right)
+
+	left, leftOk := literalValue(expr.left)
+	right, rightOk := literalValue(expr.right)
+	if leftOk && rightOk {
+		if value, ok := foldBinary(expr.operator.tokenType, left, right); ok {
+			return &LiteralExpr{value: value, token: expr.operator, folded: true}
+		}
+	}
+	return expr
+}
+
+// Optimizes the callee and the arguments (positional and keyword)
+func (o *Optimizer) visitCallExpr(expr *CallExpr) any {
+	expr.callee = o.optimizeExpr(expr.callee)
+	o.optimizeExprs(expr.arguments)
+	for j := range expr.keywords {
+		expr.keywords[j].value = o.optimizeExpr(expr.keywords[j].value)
+	}
+	return expr
+}
+
+// Optimizes the chain
+func (o *Optimizer) visitChainExpr(expr *ChainExpr) any {
+	expr.expression = o.optimizeExpr(expr.expression)
+	return expr
+}
+
+// Optimizes the value, and the expressions within each target
+func (o *Optimizer) visitDestructureExpr(expr *DestructureExpr) any {
+	expr.value = o.optimizeExpr(expr.value)
+	o.optimizeTargets(expr.targets)
+	return expr
+}
+
+// Optimizes the objects and indices of destructuring targets, leaving the targets themselves in place
+func (o *Optimizer) optimizeTargets(targets []Expr) {
+	for _, target := range targets {
+		if spread, ok := target.(*SpreadExpr); ok {
+			target = spread.expression
+		}
+
+		switch target := target.(type) {
+		case *GetExpr:
+			target.object = o.optimizeExpr(target.object)
+		case *IndexExpr:
+			target.indexee = o.optimizeExpr(target.indexee)
+			target.start = o.optimizeExpr(target.start)
+		case *ListExpr:
+			o.optimizeTargets(target.elements)
+		}
+	}
+}
+
+// Optimizes the object
+func (o *Optimizer) visitGetExpr(expr *GetExpr) any {
+	expr.object = o.optimizeExpr(expr.object)
+	return expr
+}
+
+// Replaces the grouping with the expression inside it
+func (o *Optimizer) visitGroupingExpr(expr *GroupingExpr) any {
+	return o.optimizeExpr(expr.expression)
+}
+
+// Optimizes the indexee and the indices
+func (o *Optimizer) visitIndexExpr(expr *IndexExpr) any {
+	expr.indexee = o.optimizeExpr(expr.indexee)
+	expr.start = o.optimizeExpr(expr.start)
+	if expr.stop != nil {
+		expr.stop = o.optimizeExpr(expr.stop)
+	}
+	return expr
+}
+
+// Optimizes each element
+func (o *Optimizer) visitListExpr(expr *ListExpr) any {
+	o.optimizeExprs(expr.elements)
+	return expr
+}
+
+// Does nothing, literals are already as simple as they can be
+func (*Optimizer) visitLiteralExpr(expr *LiteralExpr) any {
+	return expr
+}
+
+// Replaces the operation with the operand it evaluates to, if the left operand is a literal
+func (o *Optimizer) visitLogicalExpr(expr *LogicalExpr) any {
+	expr.left = o.optimizeExpr(expr.left)
+	expr.right = o.optimizeExpr(expr.right)
+
+	if left, ok := literalValue(expr.left); ok {
+		switch expr.operator.tokenType {
+		case AND:
+			if !isTruthy(left) {
+				return expr.left
+			}
+			return expr.right
+		case OR:
+			if isTruthy(left) {
+				return expr.left
+			}
+			return expr.right
+		case QUESTION_QUESTION:
+			if left != nil {
+				return expr.left
+			}
+			return expr.right
+		}
+	}
+	return expr
+}
+
+// Optimizes the subject, then each arm in its own scope with its bindings
+func (o *Optimizer) visitMatchExpr(expr *MatchExpr) any {
+	expr.subject = o.optimizeExpr(expr.subject)
+
+	for j := range expr.arms {
+		arm := &expr.arms[j]
+		o.beginScope()
+		for _, name := range arm.bindings {
+			o.declare(name, nil)
+		}
+
+		if arm.guard != nil {
+			arm.guard = o.optimizeExpr(arm.guard)
+		}
+		if arm.body != nil {
+			arm.body = o.optimizeBody(arm.body)
+		} else {
+			arm.value = o.optimizeExpr(arm.value)
+		}
+		o.endScope()
+	}
+	return expr
+}
+
+// Optimizes indexee, index, and value
+func (o *Optimizer) visitReplaceExpr(expr *ReplaceExpr) any {
+	expr.indexee = o.optimizeExpr(expr.indexee)
+	expr.index = o.optimizeExpr(expr.index)
+	expr.value = o.optimizeExpr(expr.value)
+	return expr
+}
+
+// Optimizes the object and the value
+func (o *Optimizer) visitSetExpr(expr *SetExpr) any {
+	expr.object = o.optimizeExpr(expr.object)
+	expr.value = o.optimizeExpr(expr.value)
+	return expr
+}
+
+// Optimizes the call, which stays a call
+func (o *Optimizer) visitSpawnExpr(expr *SpawnExpr) any {
+	o.visitCallExpr(expr.call)
+	return expr
+}
+
+// Optimizes the spread expression
+func (o *Optimizer) visitSpreadExpr(expr *SpreadExpr) any {
+	expr.expression = o.optimizeExpr(expr.expression)
+	return expr
+}
+
+// Replaces the ternary with the value that is chosen, if the condition is a literal
+func (o *Optimizer) visitTernaryExpr(expr *TernaryExpr) any {
+	expr.condition = o.optimizeExpr(expr.condition)
+	expr.trueValue = o.optimizeExpr(expr.trueValue)
+	expr.falseValue = o.optimizeExpr(expr.falseValue)
+
+	if condition, ok := literalValue(expr.condition); ok {
+		if isTruthy(condition) {
+			return expr.trueValue
+		}
+		return expr.falseValue
+	}
+	return expr
+}
+
+// Does nothing, "this" is never a constant
+func (*Optimizer) visitThisExpr(expr *ThisExpr) any {
+	return expr
+}
+
+// Folds the operation if the operand is a literal
+func (o *Optimizer) visitUnaryExpr(expr *UnaryExpr) any {
+	expr.operand = o.optimizeExpr(expr.operand)
+
+	if operand, ok := literalValue(expr.operand); ok {
+		switch {
+		case expr.operator.tokenType == BANG:
+			return &LiteralExpr{value: !isTruthy(operand), token: expr.operator, folded: true}
+		case expr.operator.tokenType == MINUS && isNumber(operand):
+			return &LiteralExpr{value: negate(operand), token: expr.operator, folded: true}
+		case expr.operator.tokenType == PLUS && isNumber(operand):
+			return &LiteralExpr{value: operand, token: expr.operator, folded: true}
+		}
+	}
+	return expr
+}
+
+// Inlines the variable if it is a constant
+func (o *Optimizer) visitVariableExpr(expr *VariableExpr) any {
+	if value := o.lookup(expr.Token); value != nil {
+		return &LiteralExpr{value: value.value, token: expr.Token, folded: true}
+	}
+	return expr
+}
+
+// Optimizes the value
+func (o *Optimizer) visitYieldExpr(expr *YieldExpr) any {
+	if expr.value != nil {
+		expr.value = o.optimizeExpr(expr.value)
+	}
+	return expr
+}
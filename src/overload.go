@@ -0,0 +1,53 @@
+// Ward Jaeger, CS 403
+package main
+
+// Special methods that overload binary operators, along with the method tried on the right operand
+// Arithmetic falls back to a reflected method, while comparisons fall back to their mirror image
+var binaryMethods = map[tokenType][2]string{
+	MINUS:             {"__sub__", "__rsub__"},
+	MINUS_EQUAL:       {"__sub__", "__rsub__"},
+	MINUS_MINUS:       {"__sub__", "__rsub__"},
+	PLUS:              {"__add__", "__radd__"},
+	PLUS_EQUAL:        {"__add__", "__radd__"},
+	PLUS_PLUS:         {"__add__", "__radd__"},
+	SLASH:             {"__div__", "__rdiv__"},
+	SLASH_EQUAL:       {"__div__", "__rdiv__"},
+	STAR:              {"__mul__", "__rmul__"},
+	STAR_EQUAL:        {"__mul__", "__rmul__"},
+	TILDE_SLASH:       {"__floordiv__", "__rfloordiv__"},
+	TILDE_SLASH_EQUAL: {"__floordiv__", "__rfloordiv__"},
+	GREATER:           {"__gt__", "__lt__"},
+	GREATER_EQUAL:     {"__ge__", "__le__"},
+	LESS:              {"__lt__", "__gt__"},
+	LESS_EQUAL:        {"__le__", "__ge__"},
+}
+
+// Special methods that overload unary operators
+var unaryMethods = map[tokenType]string{
+	MINUS: "__neg__",
+	PLUS:  "__pos__",
+}
+
+// Call a special method of an instance, if its class defines one with the given name
+// Returns whether the method was found, along with its return value
+func (i *Interpreter) callSpecial(value any, name string, token Token, args ...any) (any, bool) {
+	if instance, ok := value.(*Instance); ok {
+		if method := instance.findMethod(name); method != nil {
+			bound := instance.bindMethod(i, method)
+			return bound.call(i, bindArguments(bound, args, map[string]any{}, token)), true
+		}
+	}
+	return nil, false
+}
+
+// Try to overload a binary operator, first on the left operand, then on the right
+func (i *Interpreter) overloadBinary(operator Token, left any, right any) (any, bool) {
+	methods, found := binaryMethods[operator.tokenType]
+	if !found {
+		return nil, false
+	}
+	if result, ok := i.callSpecial(left, methods[0], operator, right); ok {
+		return result, true
+	}
+	return i.callSpecial(right, methods[1], operator, left)
+}
@@ -1,12 +1,13 @@
 // Ward Jaeger, CS 403
 package main
 
-import "strconv"
-
 // Converts a list of tokens into an AST
 type Parser struct {
-	tokens  []Token // Tokens to parse
-	current int     // Index of current token
+	tokens        []Token      // Tokens to parse
+	current       int          // Index of current token
+	lines         map[Stmt]int // Lines that statements start on, shared with the interpreter
+	colons        int          // Ternary operations and slices in the current expression that are still waiting for a ':'
+	bracketOpened bool         // Whether the next primary is a list whose '[' was consumed as part of a ternary's "?["
 }
 
 // Entry point to begin parsing tokens
@@ -20,7 +21,10 @@ func (p *Parser) parse() []Stmt {
 }
 
 // Any type of declaration or statement
-func (p *Parser) declaration() Stmt {
+func (p *Parser) declaration() (stmt Stmt) {
+	line := p.peek().line
+	defer func() { p.markLine(stmt, line) }()
+
 	// Set up a deferred function that handles Parse errors
 	defer func() {
 		if r := recover(); r != nil {
@@ -35,6 +39,16 @@ func (p *Parser) declaration() Stmt {
 	if p.match(CLASS) {
 		return p.classDeclaration()
 	}
+	if p.match(TRAIT) {
+		return p.traitDeclaration()
+	}
+	if p.check(AT) {
+		decorators := p.decorators()
+		p.consume(FUN, "Expect 'fun' after decorators.")
+		function := p.function("function")
+		function.decorators = decorators
+		return function
+	}
 	if p.match(FUN) {
 		return p.function("function")
 	}
@@ -43,56 +57,281 @@ func (p *Parser) declaration() Stmt {
 		defer p.terminator("Expect terminator after variable declaration.")
 		return p.varDeclaration()
 	}
+	if p.match(LET) {
+		// Check for terminator after constant declaration
+		defer p.terminator("Expect terminator after constant declaration.")
+		return p.letDeclaration()
+	}
 	return p.statement()
 }
 
+// Record the line that a statement starts on
+// Blocks are left out, since they do nothing on their own line
+func (p *Parser) markLine(stmt Stmt, line int) {
+	if _, ok := stmt.(*BlockStmt); ok || stmt == nil || p.lines == nil {
+		return
+	}
+	p.lines[stmt] = line
+}
+
 // Declare a new class
 func (p *Parser) classDeclaration() *ClassStmt {
 	name := p.consume(IDENTIFIER, "Expect class name.")
+	traits := []*VariableExpr{}
+	if p.match(WITH) {
+		for {
+			traits = append(traits, &VariableExpr{p.consume(IDENTIFIER, "Expect trait name.")})
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
 	p.consume(LEFT_BRACE, "Expect '{' before class body.")
 
-	methods := []*FunctionStmt{}
+	class := &ClassStmt{name: name, traits: traits, methods: []*FunctionStmt{}, setters: []*FunctionStmt{},
+		staticMethods: []*FunctionStmt{}, staticFields: []*VarStmt{}}
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		methods = append(methods, p.function("method"))
+		p.member(class)
 	}
 
 	p.consume(RIGHT_BRACE, "Expect '}' after class body.")
 
-	return &ClassStmt{name: name, methods: methods}
+	return class
+}
+
+// Declare a trait
+func (p *Parser) traitDeclaration() *TraitStmt {
+	name := p.consume(IDENTIFIER, "Expect trait name.")
+	p.consume(LEFT_BRACE, "Expect '{' before trait body.")
+
+	trait := &TraitStmt{name: name, required: []*FunctionStmt{}, methods: []*FunctionStmt{}}
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		// Default methods may optionally start with "fun"
+		p.match(FUN)
+		method := p.signature("method")
+		if method.name.tokenType == PRIVATE_NAME {
+			reportToken(method.name, "Traits can't have private methods.")
+		}
+		if p.match(LEFT_BRACE) {
+			method.body = p.block()
+			trait.methods = append(trait.methods, method)
+		} else {
+			p.terminator("Expect terminator after required method.")
+			trait.required = append(trait.required, method)
+		}
+	}
+
+	p.consume(RIGHT_BRACE, "Expect '}' after trait body.")
+	return trait
+}
+
+// A single member of a class body, which is added to the class
+func (p *Parser) member(class *ClassStmt) {
+	decorators := p.decorators()
+	start, static := p.peek(), p.check(STATIC)
+	function := p.undecoratedMember(class)
+	if len(decorators) == 0 {
+		return
+	}
+
+	// An initializer always gives the instance, so it can't be replaced by what a decorator returns
+	if function == nil {
+		reportToken(start, "Only functions and methods can be decorated.")
+	} else if function.name.lexeme == "init" && !static {
+		reportToken(function.name, "Initializers can't be decorated.")
+	} else {
+		function.decorators = decorators
+	}
+}
+
+// Class member without its decorators, returning the function it declares, or nil for a static field
+func (p *Parser) undecoratedMember(class *ClassStmt) *FunctionStmt {
+	if p.match(STATIC) {
+		next := p.peekNext().tokenType
+		if p.checkName() && next != LEFT_PAREN && next != LEFT_BRACE {
+			// Check for terminator after static field
+			defer p.terminator("Expect terminator after static field.")
+			name := p.memberName("Expect static field name.")
+			class.staticFields = append(class.staticFields, p.finishVarDeclaration(name, nil))
+			return nil
+		}
+		method := p.method("static method")
+		class.staticMethods = append(class.staticMethods, method)
+		return method
+	}
+
+	// "set" is only special when it is followed by the name of the setter
+	next := p.peekNext().tokenType
+	if p.check(IDENTIFIER) && p.peek().lexeme == "set" && (next == IDENTIFIER || next == PRIVATE_NAME) {
+		p.advance()
+		setter := p.function("setter")
+		if len(setter.params) != 1 || setter.params[0].isRest || setter.params[0].defaultValue != nil {
+			reportToken(setter.name, "Setter must have exactly one required parameter.")
+		}
+		class.setters = append(class.setters, setter)
+		return setter
+	}
+
+	method := p.method("method")
+	class.methods = append(class.methods, method)
+	return method
+}
+
+// Method, which is a getter if its name is followed directly by its body
+func (p *Parser) method(kind string) *FunctionStmt {
+	if p.checkName() && p.peekNext().tokenType == LEFT_BRACE {
+		name := p.advance()
+		p.advance()
+		return &FunctionStmt{name: name, params: []Param{}, body: p.block(), isGetter: true}
+	}
+	return p.function(kind)
+}
+
+// Decorators before a function, each of which is a callable
+func (p *Parser) decorators() []Expr {
+	decorators := []Expr{}
+	for p.match(AT) {
+		decorators = append(decorators, p.postfix())
+	}
+	return decorators
+}
+
+// Name of a class member or property, which may be private
+func (p *Parser) memberName(errorMessage string) Token {
+	if p.match(PRIVATE_NAME) {
+		return p.previous()
+	}
+	return p.consume(IDENTIFIER, errorMessage)
 }
 
 // Parse some sort of function
 func (p *Parser) function(kind string) *FunctionStmt {
-	name := p.consume(IDENTIFIER, "Expect "+kind+" name.")
+	function := p.signature(kind)
+	p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body.")
+	function.body = p.block()
+	return function
+}
+
+// Parse the name and parameters of some sort of function, leaving the body empty
+func (p *Parser) signature(kind string) *FunctionStmt {
+	// Only members of classes can have private names
+	var name Token
+	if kind == "function" {
+		name = p.consume(IDENTIFIER, "Expect "+kind+" name.")
+	} else {
+		name = p.memberName("Expect " + kind + " name.")
+	}
 	p.consume(LEFT_PAREN, "Expect '(' after "+kind+" name.")
-	parameters := []Token{}
+	parameters := []Param{}
 	if !p.check(RIGHT_PAREN) {
-		parameters = append(parameters, p.consume(IDENTIFIER, "Expect paramter name."))
+		parameters = append(parameters, p.parameter(parameters))
 		for p.match(COMMA) {
-			parameters = append(parameters, p.consume(IDENTIFIER, "Expect parameter name."))
+			parameters = append(parameters, p.parameter(parameters))
 		}
 	}
 	p.consume(RIGHT_PAREN, "Expect ')' after parameters.")
+	return &FunctionStmt{name: name, params: parameters, returnType: p.annotation()}
+}
 
-	p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body.")
-	body := p.block()
-	return &FunctionStmt{name: name, params: parameters, body: body}
+// A single parameter, which may have a default value or collect the rest of the arguments
+func (p *Parser) parameter(previous []Param) Param {
+	if len(previous) > 0 && previous[len(previous)-1].isRest {
+		reportToken(p.peek(), "Rest parameter must be last.")
+	}
+
+	if p.match(ELLIPSIS) {
+		name := p.consume(IDENTIFIER, "Expect parameter name after '...'.")
+		return Param{name: name, annotation: p.annotation(), isRest: true}
+	}
+
+	name := p.consume(IDENTIFIER, "Expect parameter name.")
+	annotation := p.annotation()
+	if p.match(EQUAL) {
+		return Param{name: name, annotation: annotation, defaultValue: p.expression()}
+	}
+	if len(previous) > 0 && previous[len(previous)-1].defaultValue != nil {
+		reportToken(name, "Required parameter can't follow a default parameter.")
+	}
+	return Param{name: name, annotation: annotation}
+}
+
+// Type annotation after a colon, or nil if there is none
+func (p *Parser) annotation() *TypeExpr {
+	if !p.match(COLON) {
+		return nil
+	}
+	return p.typeExpr()
+}
+
+// A type name, which for lists may be followed by an element type,
+// and may be followed by '?' to also allow nil
+func (p *Parser) typeExpr() *TypeExpr {
+	var name Token
+	if p.match(NIL) {
+		name = p.previous()
+	} else {
+		name = p.consume(IDENTIFIER, "Expect type name.")
+	}
+
+	typeExpr := &TypeExpr{name: name}
+	if p.match(LESS) {
+		if name.lexeme != "list" {
+			reportToken(name, "Only list types can have an element type.")
+		}
+		typeExpr.element = p.typeExpr()
+		p.consume(GREATER, "Expect '>' after element type.")
+	}
+	typeExpr.nullable = p.match(QUESTION)
+	return typeExpr
 }
 
 // Declare a new variable
 func (p *Parser) varDeclaration() *VarStmt {
-	name := p.consume(IDENTIFIER, "Expect variable name.")
+	return p.finishVarDeclaration(p.varTarget())
+}
+
+// Declare a new constant, which must have an initializer
+func (p *Parser) letDeclaration() *VarStmt {
+	stmt := p.varDeclaration()
+	if stmt.initializer == nil {
+		reportToken(p.previous(), "Expect '=' after constant name.")
+	}
+	stmt.constant = true
+	return stmt
+}
+
+// Variable name, or destructuring pattern (along with its first token)
+func (p *Parser) varTarget() (Token, Pattern) {
+	if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+		start := p.peek()
+		pattern := p.pattern()
+		patternBindings([]Pattern{pattern})
+		return start, pattern
+	}
+	return p.consume(IDENTIFIER, "Expect variable name."), nil
+}
+
+// Complete a variable declaration, which must have an initializer if destructuring
+func (p *Parser) finishVarDeclaration(name Token, pattern Pattern) *VarStmt {
+	if pattern != nil {
+		p.consume(EQUAL, "Expect '=' after destructuring pattern.")
+		return &VarStmt{name: name, pattern: pattern, initializer: p.expression()}
+	}
 
+	annotation := p.annotation()
 	var initializer Expr
 	if p.match(EQUAL) {
 		initializer = p.expression()
 	}
 
-	return &VarStmt{name: name, initializer: initializer}
+	return &VarStmt{name: name, annotation: annotation, initializer: initializer}
 }
 
 // Get some other kind of statement
-func (p *Parser) statement() Stmt {
+func (p *Parser) statement() (stmt Stmt) {
+	line := p.peek().line
+	defer func() { p.markLine(stmt, line) }()
+
 	if p.match(FOR) {
 		return p.forStatement()
 	}
@@ -116,13 +355,20 @@ func (p *Parser) statement() Stmt {
 	return p.expressionStatement()
 }
 
-// For statement, just syntactic sugar
+// For statement, either a for-in loop or just syntactic sugar
 func (p *Parser) forStatement() Stmt {
+	line := p.previous().line
 	p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
 
 	var initializer Stmt
 	if p.match(VAR) {
-		initializer = p.varDeclaration()
+		name, pattern := p.varTarget()
+		if p.match(IN) {
+			iterable := p.expression()
+			p.consume(RIGHT_PAREN, "Expect ')' after for clauses.")
+			return &ForInStmt{name: name, pattern: pattern, iterable: iterable, body: p.statement()}
+		}
+		initializer = p.finishVarDeclaration(name, pattern)
 	} else if !p.check(SEMICOLON) {
 		initializer = p.expressionStatement()
 	}
@@ -134,7 +380,7 @@ func (p *Parser) forStatement() Stmt {
 		condition = p.expression()
 	}
 	// Note: Semicolon is necessary here
-	p.consume(SEMICOLON, "Expect ';' after loop condition.")
+	semicolon := p.consume(SEMICOLON, "Expect ';' after loop condition.")
 
 	var increment Stmt
 	if !p.check(RIGHT_PAREN) {
@@ -144,6 +390,10 @@ func (p *Parser) forStatement() Stmt {
 
 	body := p.statement()
 
+	// The statements that the loop is made of all belong to its line
+	p.markLine(initializer, line)
+	p.markLine(increment, line)
+
 	// Body always ends with the increment
 	if increment != nil {
 		body = &BlockStmt{statements: []Stmt{body, increment}}
@@ -151,9 +401,10 @@ func (p *Parser) forStatement() Stmt {
 
 	// Body is executed when condition is true
 	if condition == nil {
-		condition = &LiteralExpr{value: true}
+		condition = &LiteralExpr{value: true, token: semicolon}
 	}
 	body = &WhileStmt{condition: condition, body: body}
+	p.markLine(body, line)
 
 	// Initializer kicks the whole thing off
 	if initializer != nil {
@@ -227,20 +478,51 @@ func (p *Parser) expressionStatement() *ExpressionStmt {
 	return &ExpressionStmt{expression: p.expression()}
 }
 
-// Expression (reduces immediately to assignment)
+// Expression (reduces immediately to assignment, unless yielding)
 func (p *Parser) expression() Expr {
+	return p.expressionWithin(0)
+}
+
+// Expression that is followed by a ':' of its own, like the start of a slice
+func (p *Parser) expressionBeforeColon() Expr {
+	return p.expressionWithin(1)
+}
+
+// Expression with a given number of operators waiting for a ':' after it
+// A nested expression is enclosed by brackets, so it can't hold the ':' of a ternary operation outside of it
+func (p *Parser) expressionWithin(colons int) Expr {
+	enclosingColons := p.colons
+	p.colons = colons
+	defer func() { p.colons = enclosingColons }()
+
+	if p.match(YIELD) {
+		return p.yieldExpression()
+	}
 	return p.assignment()
 }
 
+// Yield expression, whose value is optional if nothing follows on the same line
+func (p *Parser) yieldExpression() *YieldExpr {
+	keyword := p.previous()
+	if !p.sameLine() || p.isAtEnd() || p.check(SEMICOLON) || p.check(RIGHT_BRACE) ||
+		p.check(RIGHT_PAREN) || p.check(RIGHT_BRACKET) || p.check(COMMA) {
+		return &YieldExpr{keyword: keyword}
+	}
+	return &YieldExpr{keyword: keyword, value: p.expression()}
+}
+
 // Assignment
 func (p *Parser) assignment() Expr {
 	expr := p.ternary()
 
-	if p.match(EQUAL, MINUS_EQUAL, PLUS_EQUAL, SLASH_EQUAL, STAR_EQUAL) {
+	if p.match(EQUAL, MINUS_EQUAL, PLUS_EQUAL, SLASH_EQUAL, STAR_EQUAL, TILDE_SLASH_EQUAL,
+		QUESTION_QUESTION_EQUAL) {
 		equals := p.previous()
 		value := p.assignment()
 		// Compound assignment operators get expanded
-		if equals.tokenType != EQUAL {
+		if equals.tokenType == QUESTION_QUESTION_EQUAL {
+			value = &LogicalExpr{left: expr, operator: equals, right: value}
+		} else if equals.tokenType != EQUAL {
 			value = &BinaryExpr{left: expr, operator: equals, right: value}
 		}
 		return p.finishAssignment(expr, equals, value)
@@ -250,9 +532,14 @@ func (p *Parser) assignment() Expr {
 }
 
 // Verify assignment target, and complete assignment expression
-func (*Parser) finishAssignment(target Expr, operator Token, value Expr) Expr {
+func (p *Parser) finishAssignment(target Expr, operator Token, value Expr) Expr {
 	// Check for correct assignment target, as indicated by the grammar
-	if name, ok := target.(*VariableExpr); ok {
+	if list, ok := target.(*ListExpr); ok && operator.tokenType == EQUAL {
+		if !p.checkDestructureTargets(list) {
+			return target
+		}
+		return &DestructureExpr{targets: list.elements, equals: operator, value: value}
+	} else if name, ok := target.(*VariableExpr); ok {
 		return &AssignExpr{name: name.Token, value: value}
 	} else if get, ok := target.(*GetExpr); ok {
 		return &SetExpr{object: get.object, name: get.name, value: value}
@@ -265,15 +552,53 @@ func (*Parser) finishAssignment(target Expr, operator Token, value Expr) Expr {
 	return target
 }
 
+// Check that every element of a list is a valid destructuring target, reporting any that are not
+func (p *Parser) checkDestructureTargets(list *ListExpr) bool {
+	valid := true
+	for j, element := range list.elements {
+		target := element
+		if spread, ok := element.(*SpreadExpr); ok {
+			if j != len(list.elements)-1 {
+				reportToken(spread.ellipsis, "Spread must be the last destructuring target.")
+				valid = false
+			}
+			target = spread.expression
+		}
+
+		switch target := target.(type) {
+		case *VariableExpr:
+		case *GetExpr:
+		case *IndexExpr:
+			if target.stop != nil {
+				reportToken(target.bracket, "Can't assign to a slice.")
+				valid = false
+			}
+		case *ListExpr:
+			valid = p.checkDestructureTargets(target) && valid
+		default:
+			reportToken(list.bracket, "Invalid destructuring target.")
+			valid = false
+		}
+	}
+	return valid
+}
+
 // Ternary operator
 func (p *Parser) ternary() Expr {
-	expr := p.or()
+	expr := p.coalesce()
 
-	for p.match(QUESTION) {
+	for p.match(QUESTION, QUESTION_LEFT_BRACKET) {
 		operator := p.previous()
-		trueValue := p.or()
+		if operator.tokenType == QUESTION_LEFT_BRACKET {
+			// A "?[" left here by postfix is a '?' followed by a list, like "c ?[1] : [2]"
+			p.bracketOpened = true
+			operator = Token{tokenType: QUESTION, lexeme: "?", line: operator.line, col: operator.col}
+		}
+		p.colons++
+		trueValue := p.coalesce()
+		p.colons--
 		p.consume(COLON, "Expect ':' after expression.")
-		falseValue := p.or()
+		falseValue := p.coalesce()
 		expr = &TernaryExpr{condition: expr, operator: operator,
 			trueValue: trueValue, falseValue: falseValue}
 	}
@@ -281,6 +606,19 @@ func (p *Parser) ternary() Expr {
 	return expr
 }
 
+// Nil-coalescing expression
+func (p *Parser) coalesce() Expr {
+	expr := p.or()
+
+	for p.match(QUESTION_QUESTION) {
+		operator := p.previous()
+		right := p.or()
+		expr = &LogicalExpr{left: expr, operator: operator, right: right}
+	}
+
+	return expr
+}
+
 // Logical or expression
 func (p *Parser) or() Expr {
 	expr := p.and()
@@ -346,11 +684,11 @@ func (p *Parser) term() Expr {
 	return expr
 }
 
-// Multiplication, division
+// Multiplication, division, integer division
 func (p *Parser) factor() Expr {
 	expr := p.prefix()
 
-	for p.match(SLASH, STAR) {
+	for p.match(SLASH, STAR, TILDE_SLASH) {
 		operator := p.previous()
 		right := p.prefix()
 		expr = &BinaryExpr{left: expr, operator: operator, right: right}
@@ -361,11 +699,24 @@ func (p *Parser) factor() Expr {
 
 // Unary prefix operations
 func (p *Parser) prefix() Expr {
-	if p.match(BANG, MINUS, PLUS) {
+	// A list opened by a ternary's "?[" comes before any prefix operator
+	if p.bracketOpened {
+		return p.increment()
+	}
+	if p.match(AWAIT, BANG, MINUS, PLUS) {
 		operator := p.previous()
 		right := p.prefix()
 		return &UnaryExpr{operator: operator, operand: right}
 	}
+	if p.match(SPAWN) {
+		keyword := p.previous()
+		call, ok := p.postfix().(*CallExpr)
+		if !ok {
+			reportToken(keyword, "Expect function call after 'spawn'.")
+			panic(ParseError{})
+		}
+		return &SpawnExpr{keyword: keyword, call: call}
+	}
 
 	return p.increment()
 }
@@ -377,50 +728,73 @@ func (p *Parser) increment() Expr {
 	if p.match(MINUS_MINUS, PLUS_PLUS) {
 		operator := p.previous()
 		value := &BinaryExpr{left: expr, operator: operator,
-			right: &LiteralExpr{value: 1.0}}
+			right: &LiteralExpr{value: int64(1), token: operator}}
 		return p.finishAssignment(expr, operator, value)
 	}
 
 	return expr
 }
 
-// Instance getting, calls, and list indexing/slicing
+// Instance getting, calls, and list indexing/slicing, any of which may be optional
+// Calls and indices must start on the same line, so that a new line can start with a list
 func (p *Parser) postfix() Expr {
 	expr := p.primary()
+	optional := false
 
 	for {
 		if p.match(DOT) {
-			name := p.consume(IDENTIFIER,
-				"Expect property name after '.'.")
+			name := p.memberName("Expect property name after '.'.")
 			expr = &GetExpr{object: expr, name: name}
-		} else if p.match(LEFT_PAREN) {
-			args := []Expr{}
-			if !p.check(RIGHT_PAREN) {
-				args = p.arguments()
-			}
-			paren := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
-			expr = &CallExpr{callee: expr, arguments: args, paren: paren}
-		} else if p.match(LEFT_BRACKET) {
+		} else if p.sameLine() && p.match(LEFT_PAREN) {
+			expr = p.finishCall(expr)
+		} else if p.sameLine() && p.match(LEFT_BRACKET) {
 			expr = p.finishIndex(expr)
+		} else if p.match(QUESTION_DOT) {
+			optional = true
+			if p.match(LEFT_PAREN) {
+				call := p.finishCall(expr)
+				call.optional = true
+				expr = call
+			} else {
+				name := p.memberName("Expect property name or '(' after '?.'.")
+				expr = &GetExpr{object: expr, name: name, optional: true}
+			}
+		} else if p.check(QUESTION_LEFT_BRACKET) && p.colons == 0 && p.colonAfterBrackets() {
+			// Something like "c ?[1] : [2]" is a ternary operation on a list, as it was before optional indices,
+			// as long as nothing else is waiting for the ':'
+			break
+		} else if p.match(QUESTION_LEFT_BRACKET) {
+			optional = true
+			index := p.finishIndex(expr)
+			index.optional = true
+			expr = index
 		} else {
 			break
 		}
 	}
 
+	// Optional links short-circuit the entire chain
+	if optional {
+		return &ChainExpr{expression: expr}
+	}
 	return expr
 }
 
 // Lowest level, matches to many literal values
 func (p *Parser) primary() Expr {
+	if p.bracketOpened {
+		p.bracketOpened = false
+		return p.finishList()
+	}
+
 	if p.match(FALSE) {
-		return &LiteralExpr{value: false}
+		return &LiteralExpr{value: false, token: p.previous()}
 	} else if p.match(TRUE) {
-		return &LiteralExpr{value: true}
+		return &LiteralExpr{value: true, token: p.previous()}
 	} else if p.match(NIL) {
-		return &LiteralExpr{value: nil}
+		return &LiteralExpr{value: nil, token: p.previous()}
 	} else if p.match(NUMBER) {
-		value, _ := strconv.ParseFloat(p.previous().lexeme, 64)
-		return &LiteralExpr{value: value}
+		return &LiteralExpr{value: parseNumber(p.previous().lexeme), token: p.previous()}
 	}
 
 	if p.match(STRING) {
@@ -446,13 +820,17 @@ func (p *Parser) primary() Expr {
 				str = append(str, prev.lexeme[i])
 			}
 		}
-		return &LiteralExpr{value: Sequence{list: str, isString: true}}
+		return &LiteralExpr{value: Sequence{list: str, isString: true}, token: prev}
 	}
 
 	if p.match(THIS) {
 		return &ThisExpr{Token: p.previous()}
 	}
 
+	if p.match(MATCH) {
+		return p.matchExpression()
+	}
+
 	if p.match(IDENTIFIER) {
 		return &VariableExpr{p.previous()}
 	}
@@ -464,34 +842,202 @@ func (p *Parser) primary() Expr {
 	}
 
 	if p.match(LEFT_BRACKET) {
-		elems := []Expr{}
-		if !p.check(RIGHT_BRACKET) {
-			elems = p.arguments()
-		}
-		bracket := p.consume(RIGHT_BRACKET, "Expect ']' after elements.")
-		return &ListExpr{elements: elems, bracket: bracket}
+		return p.finishList()
 	}
 
 	reportToken(p.peek(), "Expect expression.")
 	panic(ParseError{})
 }
 
-// A list of comma-separated values
+// Elements of a list literal, after its '['
+func (p *Parser) finishList() *ListExpr {
+	elems := []Expr{}
+	if !p.check(RIGHT_BRACKET) {
+		elems = p.arguments()
+	}
+	bracket := p.consume(RIGHT_BRACKET, "Expect ']' after elements.")
+	return &ListExpr{elements: elems, bracket: bracket}
+}
+
+// Match expression, made up of arms that each have patterns and a body
+func (p *Parser) matchExpression() *MatchExpr {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expect '(' after 'match'.")
+	subject := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after match subject.")
+	p.consume(LEFT_BRACE, "Expect '{' before match arms.")
+
+	arms := []MatchArm{}
+	hasDefault := false
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		arm := MatchArm{}
+		if p.match(DEFAULT) {
+			if hasDefault {
+				reportToken(p.previous(), "Match can only have one default arm.")
+			}
+			hasDefault = true
+		} else {
+			p.consume(CASE, "Expect 'case' or 'default'.")
+			arm.patterns = []Pattern{p.pattern()}
+			for p.match(COMMA) {
+				arm.patterns = append(arm.patterns, p.pattern())
+			}
+			arm.bindings = patternBindings(arm.patterns)
+			if p.match(IF) {
+				arm.guard = p.expression()
+			}
+		}
+
+		p.consume(ARROW, "Expect '=>' before arm body.")
+		if p.match(LEFT_BRACE) {
+			arm.body = &BlockStmt{statements: p.block()}
+		} else {
+			arm.value = p.expression()
+		}
+		arms = append(arms, arm)
+
+		// Arms may optionally be separated by semicolons
+		p.match(SEMICOLON)
+	}
+
+	p.consume(RIGHT_BRACE, "Expect '}' after match arms.")
+	return &MatchExpr{keyword: keyword, subject: subject, arms: arms}
+}
+
+// A pattern to match a value against
+func (p *Parser) pattern() Pattern {
+	if p.match(LEFT_BRACKET) {
+		// List pattern
+		bracket := p.previous()
+		elements := []Pattern{}
+		var rest *BindingPattern
+		for !p.check(RIGHT_BRACKET) && !p.isAtEnd() {
+			if p.match(ELLIPSIS) {
+				rest = &BindingPattern{name: p.consume(IDENTIFIER, "Expect name after '...'.")}
+				break
+			}
+			elements = append(elements, p.pattern())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+		p.consume(RIGHT_BRACKET, "Expect ']' after list pattern.")
+		return &ListPattern{elements: elements, rest: rest, bracket: bracket}
+	}
+
+	if p.match(LEFT_BRACE) {
+		// Object pattern
+		brace := p.previous()
+		fields := []FieldPattern{}
+		for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+			name := p.consume(IDENTIFIER, "Expect field name.")
+			var pattern Pattern = &BindingPattern{name: name}
+			if p.match(COLON) {
+				pattern = p.pattern()
+			}
+			fields = append(fields, FieldPattern{name: name, pattern: pattern})
+			if !p.match(COMMA) {
+				break
+			}
+		}
+		p.consume(RIGHT_BRACE, "Expect '}' after object pattern.")
+		return &ObjectPattern{fields: fields, brace: brace}
+	}
+
+	if p.match(IDENTIFIER) {
+		name := p.previous()
+		if p.match(LEFT_PAREN) {
+			// Class pattern
+			args := []Pattern{}
+			for !p.check(RIGHT_PAREN) && !p.isAtEnd() {
+				args = append(args, p.pattern())
+				if !p.match(COMMA) {
+					break
+				}
+			}
+			paren := p.consume(RIGHT_PAREN, "Expect ')' after class pattern.")
+			return &ClassPattern{class: &VariableExpr{name}, args: args, paren: paren}
+		}
+		// Binding pattern
+		return &BindingPattern{name: name}
+	}
+
+	if p.match(MINUS) {
+		// Negative number literal
+		minus := p.previous()
+		number := p.consume(NUMBER, "Expect number after '-' in pattern.")
+		return &LiteralPattern{value: negate(parseNumber(number.lexeme)), token: minus}
+	}
+
+	if p.check(NUMBER) || p.check(STRING) || p.check(TRUE) || p.check(FALSE) || p.check(NIL) {
+		// Other literals are parsed like any other primary expression
+		token := p.peek()
+		return &LiteralPattern{value: p.primary().(*LiteralExpr).value, token: token}
+	}
+
+	reportToken(p.peek(), "Expect pattern.")
+	panic(ParseError{})
+}
+
+// A list of comma-separated values, any of which may be spread
 func (p *Parser) arguments() []Expr {
-	arguments := []Expr{p.expression()}
+	arguments := []Expr{p.element()}
 	for p.match(COMMA) {
-		arguments = append(arguments, p.expression())
+		arguments = append(arguments, p.element())
 	}
 	return arguments
 }
 
+// A single value in a list of arguments or elements
+func (p *Parser) element() Expr {
+	if p.match(ELLIPSIS) {
+		return &SpreadExpr{ellipsis: p.previous(), expression: p.expression()}
+	}
+	return p.expression()
+}
+
+// Positional and keyword arguments of a call
+func (p *Parser) finishCall(callee Expr) *CallExpr {
+	args := []Expr{}
+	keywords := []Keyword{}
+
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if p.check(IDENTIFIER) && p.peekNext().tokenType == COLON {
+				// Keyword argument
+				name := p.advance()
+				p.advance()
+				for _, keyword := range keywords {
+					if keyword.name.lexeme == name.lexeme {
+						reportToken(name, "Duplicate keyword argument.")
+					}
+				}
+				keywords = append(keywords, Keyword{name: name, value: p.expression()})
+			} else {
+				// Positional argument
+				if len(keywords) > 0 {
+					reportToken(p.peek(), "Positional argument can't follow a keyword argument.")
+				}
+				args = append(args, p.element())
+			}
+
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+
+	paren := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
+	return &CallExpr{callee: callee, arguments: args, keywords: keywords, paren: paren}
+}
+
 // Indexing or slicing arguments
 func (p *Parser) finishIndex(indexee Expr) *IndexExpr {
 	var start Expr
 	if p.check(COLON) {
-		start = &LiteralExpr{value: nil}
+		start = &LiteralExpr{value: nil, token: p.previous()}
 	} else {
-		start = p.expression()
+		start = p.expressionBeforeColon()
 		if p.check(RIGHT_BRACKET) {
 			return &IndexExpr{indexee: indexee, start: start, stop: nil, bracket: p.advance()}
 		}
@@ -501,7 +1047,7 @@ func (p *Parser) finishIndex(indexee Expr) *IndexExpr {
 
 	var stop Expr
 	if p.check(RIGHT_BRACKET) {
-		stop = &LiteralExpr{value: nil}
+		stop = &LiteralExpr{value: nil, token: p.previous()}
 	} else {
 		stop = p.expression()
 	}
@@ -511,11 +1057,38 @@ func (p *Parser) finishIndex(indexee Expr) *IndexExpr {
 	return &IndexExpr{indexee: indexee, start: start, stop: stop, bracket: bracket}
 }
 
+// Check whether the brackets starting at the next token are followed by a ':'
+func (p *Parser) colonAfterBrackets() bool {
+	depth := 0
+	for j := p.current; j < len(p.tokens); j++ {
+		switch p.tokens[j].tokenType {
+		case LEFT_BRACKET, QUESTION_LEFT_BRACKET:
+			depth++
+		case RIGHT_BRACKET:
+			depth--
+			if depth == 0 {
+				return j+1 < len(p.tokens) && p.tokens[j+1].tokenType == COLON
+			}
+		case EOF:
+			return false
+		}
+	}
+	return false
+}
+
 // Look at next token
 func (p *Parser) peek() Token {
 	return p.tokens[p.current]
 }
 
+// Look at the token after the next token
+func (p *Parser) peekNext() Token {
+	if p.isAtEnd() {
+		return p.peek()
+	}
+	return p.tokens[p.current+1]
+}
+
 // Look at previous token
 func (p *Parser) previous() Token {
 	return p.tokens[p.current-1]
@@ -526,6 +1099,16 @@ func (p *Parser) check(ttype tokenType) bool {
 	return p.peek().tokenType == ttype
 }
 
+// Check if next token is a name of a class member or property
+func (p *Parser) checkName() bool {
+	return p.check(IDENTIFIER) || p.check(PRIVATE_NAME)
+}
+
+// Check if next token is on the same line as the previous token
+func (p *Parser) sameLine() bool {
+	return p.previous().line == p.peek().line
+}
+
 // Check if next token is EOF
 func (p *Parser) isAtEnd() bool {
 	return p.check(EOF)
@@ -584,6 +1167,10 @@ func (p *Parser) synchronize() {
 		switch p.peek().tokenType {
 		case CLASS:
 			return
+		case TRAIT:
+			return
+		case AT:
+			return
 		case FUN:
 			return
 		case VAR:
@@ -0,0 +1,200 @@
+// Ward Jaeger, CS 403
+package main
+
+import "strconv"
+
+// Any pattern that a value can be matched against
+type Pattern interface {
+	bindings() []Token // Variables bound by a successful match
+}
+
+// Matches anything, binding it to a variable (unless the name is "_")
+type BindingPattern struct {
+	name Token
+}
+
+func (b *BindingPattern) bindings() []Token {
+	if b.name.lexeme == "_" {
+		return []Token{}
+	}
+	return []Token{b.name}
+}
+
+// Matches a value equal to a literal
+type LiteralPattern struct {
+	value any
+	token Token
+}
+
+func (*LiteralPattern) bindings() []Token {
+	return []Token{}
+}
+
+// Matches a list (or string) element by element, with the remaining elements optionally bound to rest
+type ListPattern struct {
+	elements []Pattern
+	rest     *BindingPattern // nil if there is no rest pattern
+	bracket  Token
+}
+
+func (l *ListPattern) bindings() []Token {
+	names := []Token{}
+	for _, element := range l.elements {
+		names = append(names, element.bindings()...)
+	}
+	if l.rest != nil {
+		names = append(names, l.rest.bindings()...)
+	}
+	return names
+}
+
+// Matches an instance of a class, with its fields matched in the order of the initializer's parameters
+type ClassPattern struct {
+	class *VariableExpr
+	args  []Pattern
+	paren Token
+}
+
+func (c *ClassPattern) bindings() []Token {
+	names := []Token{}
+	for _, arg := range c.args {
+		names = append(names, arg.bindings()...)
+	}
+	return names
+}
+
+// Matches an instance with the given fields
+type ObjectPattern struct {
+	fields []FieldPattern
+	brace  Token
+}
+
+// A single field of an object pattern
+type FieldPattern struct {
+	name    Token
+	pattern Pattern // Matched against the value of the field
+}
+
+func (o *ObjectPattern) bindings() []Token {
+	names := []Token{}
+	for _, field := range o.fields {
+		names = append(names, field.pattern.bindings()...)
+	}
+	return names
+}
+
+// Get the variables bound by any of a list of alternative patterns, without duplicates
+// The same variable may be bound by multiple alternatives, but only once per alternative
+func patternBindings(patterns []Pattern) []Token {
+	names := []Token{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		seenHere := map[string]bool{}
+		for _, name := range pattern.bindings() {
+			if seenHere[name.lexeme] {
+				reportToken(name, "Already a variable with this name in this pattern.")
+			}
+			seenHere[name.lexeme] = true
+			if !seen[name.lexeme] {
+				seen[name.lexeme] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Match a value against a pattern, binding variables in the given environment
+// Returns an empty string if the match succeeds, or a description of why it fails
+func (i *Interpreter) matchPattern(pattern Pattern, value any, env *Environment) string {
+	switch pattern := pattern.(type) {
+	case *BindingPattern:
+		if pattern.name.lexeme != "_" {
+			env.define(pattern.name.lexeme, value)
+		}
+		return ""
+
+	case *LiteralPattern:
+		if !i.compare(pattern.value, value, pattern.token) {
+			return "Expect " + i.stringify(pattern.value, true, pattern.token) + "."
+		}
+		return ""
+
+	case *ListPattern:
+		sequence, ok := value.(Sequence)
+		if !ok {
+			return "Expect string or list."
+		}
+		if pattern.rest == nil && sequence.size() != len(pattern.elements) {
+			return "Expect length " + strconv.Itoa(len(pattern.elements)) + "."
+		} else if sequence.size() < len(pattern.elements) {
+			return "Expect length of at least " + strconv.Itoa(len(pattern.elements)) + "."
+		}
+
+		for j, element := range pattern.elements {
+			item := sequence.element(j)
+			if sequence.isString {
+				// Elements of a string are strings of length 1
+				item = Sequence{list: []any{item}, isString: true}
+			}
+			if reason := i.matchPattern(element, item, env); reason != "" {
+				return reason
+			}
+		}
+		if pattern.rest != nil {
+			rest := sequence.slice(len(pattern.elements), sequence.size())
+			i.matchPattern(pattern.rest, Sequence{list: rest, isString: sequence.isString}, env)
+		}
+		return ""
+
+	case *ClassPattern:
+		class, ok := i.evaluateIn(pattern.class, env).(*Class)
+		if !ok {
+			panic(RuntimeError{token: pattern.class.Token,
+				message: "Class pattern must name a class."})
+		}
+		instance, ok := value.(*Instance)
+		if !ok || instance.Class != class {
+			return "Expect instance of " + class.name + "."
+		}
+
+		// Positional patterns match the fields named by the initializer's parameters
+		params := []Param{}
+		if initializer := class.findMethod("init"); initializer != nil {
+			params = initializer.declaration.params
+		}
+		if len(pattern.args) > len(params) {
+			panic(RuntimeError{token: pattern.paren, message: "Class pattern for " + class.name +
+				" can have at most " + strconv.Itoa(len(params)) + " fields."})
+		}
+		for j, arg := range pattern.args {
+			field, found := instance.getField(params[j].name.lexeme)
+			if !found {
+				return "Expect field '" + params[j].name.lexeme + "'."
+			}
+			if reason := i.matchPattern(arg, field, env); reason != "" {
+				return reason
+			}
+		}
+		return ""
+
+	case *ObjectPattern:
+		instance, ok := value.(*Instance)
+		if !ok {
+			return "Expect instance."
+		}
+		for _, field := range pattern.fields {
+			fieldValue, found := instance.getField(field.name.lexeme)
+			if !found {
+				return "Expect field '" + field.name.lexeme + "'."
+			}
+			if reason := i.matchPattern(field.pattern, fieldValue, env); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+
+	// Unreachable
+	panic(RuntimeError{message: "Unrecognized pattern."})
+}
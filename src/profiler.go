@@ -0,0 +1,215 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the frame at the bottom of every call stack in the main script
+const scriptFrame = "<script>"
+
+// Call count and times of one function
+type profileEntry struct {
+	calls     int64
+	inclusive time.Duration // Time from call to return, counted once across recursive calls
+	exclusive time.Duration // Time spent in the function itself, excluding its callees
+}
+
+// Execution count and time of one line
+type lineEntry struct {
+	count     int64
+	exclusive time.Duration // Time spent on the line itself, excluding nested statements
+}
+
+// A call or statement being timed on one goroutine
+type profileFrame struct {
+	name     string // Name of the function, for calls
+	line     int    // Line of the statement, for statements
+	start    time.Time
+	children time.Duration // Time spent in nested frames, which is not exclusive to this one
+}
+
+// Records where a run spends its time
+type profiler struct {
+	lock      sync.Mutex
+	functions map[string]*profileEntry
+	lines     map[int]*lineEntry
+	stacks    map[string]time.Duration // Exclusive time of each call stack, for folded output
+	withLines bool                     // Whether each statement is timed, which is slower
+	source    []string                 // Lines of the script, to show next to their times
+}
+
+// Build an empty profiler
+func newProfiler(withLines bool) *profiler {
+	return &profiler{functions: map[string]*profileEntry{}, lines: map[int]*lineEntry{},
+		stacks: map[string]time.Duration{}, withLines: withLines}
+}
+
+// Name a function by its name and the line it is declared on, to tell apart methods with the same name
+func (f *Function) profileName() string {
+	return f.declaration.name.lexeme + ":" + strconv.Itoa(f.declaration.name.line)
+}
+
+// Start timing a call on this goroutine
+func (i *Interpreter) enterCall(name string) {
+	i.calls = append(i.calls, profileFrame{name: name, start: time.Now()})
+}
+
+// Finish timing the innermost call on this goroutine
+func (i *Interpreter) exitCall() {
+	frame := i.calls[len(i.calls)-1]
+	i.calls = i.calls[:len(i.calls)-1]
+	elapsed := time.Since(frame.start)
+	if len(i.calls) > 0 {
+		i.calls[len(i.calls)-1].children += elapsed
+	}
+
+	// Recursive calls would count their time more than once toward inclusive time
+	names := []string{}
+	recursive := false
+	for _, caller := range i.calls {
+		names = append(names, caller.name)
+		recursive = recursive || caller.name == frame.name
+	}
+	names = append(names, frame.name)
+
+	p := i.profiler
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, ok := p.functions[frame.name]
+	if !ok {
+		entry = &profileEntry{}
+		p.functions[frame.name] = entry
+	}
+	entry.calls++
+	entry.exclusive += elapsed - frame.children
+	if !recursive {
+		entry.inclusive += elapsed
+	}
+	p.stacks[strings.Join(names, ";")] += elapsed - frame.children
+}
+
+// Start timing a statement on this goroutine
+func (i *Interpreter) enterStatement(line int) {
+	i.statements = append(i.statements, profileFrame{line: line, start: time.Now()})
+}
+
+// Finish timing the innermost statement on this goroutine
+func (i *Interpreter) exitStatement() {
+	frame := i.statements[len(i.statements)-1]
+	i.statements = i.statements[:len(i.statements)-1]
+	elapsed := time.Since(frame.start)
+	if len(i.statements) > 0 {
+		i.statements[len(i.statements)-1].children += elapsed
+	}
+
+	p := i.profiler
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, ok := p.lines[frame.line]
+	if !ok {
+		entry = &lineEntry{}
+		p.lines[frame.line] = entry
+	}
+	entry.count++
+	entry.exclusive += elapsed - frame.children
+}
+
+// Write a report of the top functions and lines to a file,
+// and the call stacks in folded format to a file next to it with the extension .folded
+func (p *profiler) write(path string, top int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := os.WriteFile(path, []byte(p.report(top)), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(foldedPath(path), []byte(p.folded()), 0644)
+}
+
+// Path of the folded output for a given report path
+func foldedPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".folded"
+}
+
+// Human-readable tables of the functions and lines with the most exclusive time
+func (p *profiler) report(top int) string {
+	var builder strings.Builder
+	milliseconds := func(d time.Duration) string {
+		return fmt.Sprintf("%12.3f", float64(d)/float64(time.Millisecond))
+	}
+
+	names := []string{}
+	for name := range p.functions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		ea, eb := p.functions[names[a]], p.functions[names[b]]
+		if ea.exclusive != eb.exclusive {
+			return ea.exclusive > eb.exclusive
+		}
+		return names[a] < names[b]
+	})
+	if len(names) > top {
+		names = names[:top]
+	}
+
+	builder.WriteString("Functions by exclusive time (ms)\n")
+	builder.WriteString(fmt.Sprintf("%10s %12s %12s  %s\n", "Calls", "Inclusive", "Exclusive", "Function"))
+	for _, name := range names {
+		entry := p.functions[name]
+		builder.WriteString(fmt.Sprintf("%10d %s %s  %s\n", entry.calls,
+			milliseconds(entry.inclusive), milliseconds(entry.exclusive), name))
+	}
+
+	if p.withLines {
+		lines := []int{}
+		for line := range p.lines {
+			lines = append(lines, line)
+		}
+		sort.Slice(lines, func(a, b int) bool {
+			ea, eb := p.lines[lines[a]], p.lines[lines[b]]
+			if ea.exclusive != eb.exclusive {
+				return ea.exclusive > eb.exclusive
+			}
+			return lines[a] < lines[b]
+		})
+		if len(lines) > top {
+			lines = lines[:top]
+		}
+
+		builder.WriteString("\nLines by exclusive time (ms)\n")
+		builder.WriteString(fmt.Sprintf("%10s %12s %6s  %s\n", "Count", "Exclusive", "Line", "Source"))
+		for _, line := range lines {
+			entry, text := p.lines[line], ""
+			if line <= len(p.source) {
+				text = strings.TrimSpace(p.source[line-1])
+			}
+			builder.WriteString(fmt.Sprintf("%10d %s %6d  %s\n", entry.count,
+				milliseconds(entry.exclusive), line, text))
+		}
+	}
+	return builder.String()
+}
+
+// Call stacks in the folded format of flame graph tools, one stack per line followed by its time in microseconds
+func (p *profiler) folded() string {
+	stacks := []string{}
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	var builder strings.Builder
+	for _, stack := range stacks {
+		builder.WriteString(stack + " " + strconv.FormatInt(p.stacks[stack].Microseconds(), 10) + "\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,170 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Build the natives that describe values at runtime, keyed by their global names
+func reflectionNatives() map[string]any {
+	return map[string]any{
+		"type": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return newString(typeName(args[0]))
+			},
+		},
+		"classOf": &Native{
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return expectInstance(args[0]).Class
+			},
+		},
+		"isInstance": &Native{
+			arityFunc: func() (int, int) { return 2, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				class, ok := args[1].(*Class)
+				if !ok {
+					panic(RuntimeError{message: "Expect class."})
+				}
+				instance, ok := args[0].(*Instance)
+				return ok && instance.Class == class
+			},
+		},
+		"fields": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				return newStringList(expectInstance(args[0]).fieldNames())
+			},
+		},
+		"methods": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				class, ok := args[0].(*Class)
+				if instance, isInstance := args[0].(*Instance); isInstance {
+					class, ok = instance.Class, true
+				}
+				if !ok {
+					panic(RuntimeError{message: "Expect class or instance."})
+				}
+
+				names := []string{}
+				for name := range class.methods {
+					if !isPrivate(name) {
+						names = append(names, name)
+					}
+				}
+				sort.Strings(names)
+				return newStringList(names)
+			},
+		},
+		"hasField": &Native{
+			arityFunc: func() (int, int) { return 2, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				name := expectString(args[1])
+				if instance, ok := args[0].(*Instance); ok && !isPrivate(name) {
+					_, found := instance.getField(name)
+					return found
+				}
+				return false
+			},
+		},
+		"getField": &Native{
+			arityFunc: func() (int, int) { return 2, 2 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				value, _ := expectInstance(args[0]).getField(expectFieldName(args[1]))
+				return value
+			},
+		},
+		"setField": &Native{
+			arityFunc: func() (int, int) { return 3, 3 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				expectInstance(args[0]).setField(expectFieldName(args[1]), args[2])
+				return args[2]
+			},
+		},
+		"arity": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				callable, ok := args[0].(Callable)
+				if !ok {
+					panic(RuntimeError{message: "Expect function or class."})
+				}
+				min, max := callable.arity()
+				if max == variadic {
+					return Sequence{list: []any{int64(min), nil}, isString: false}
+				}
+				return Sequence{list: []any{int64(min), int64(max)}, isString: false}
+			},
+		},
+		"name": &Native{
+			allocates: true,
+			arityFunc: func() (int, int) { return 1, 1 },
+			callFunc: func(_ *Interpreter, args []any) any {
+				switch value := args[0].(type) {
+				case *Function:
+					return newString(value.declaration.name.lexeme)
+				case *Native:
+					return newString(value.name)
+				case *Decorated:
+					return newString(callableName(value))
+				case *Class:
+					return newString(value.name)
+				case *Trait:
+					return newString(value.name)
+				}
+				panic(RuntimeError{message: "Expect function, class, or trait."})
+			},
+		},
+	}
+}
+
+// Name of the type of a value
+func typeName(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int64, *big.Int, float64:
+		return "number"
+	case Sequence:
+		if value.isString {
+			return "string"
+		}
+		return "list"
+	case *Function, *Native, *Decorated:
+		return "function"
+	case *Class:
+		return "class"
+	case *Instance:
+		return "instance"
+	case *Trait:
+		return "trait"
+	}
+
+	// Unreachable
+	panic(RuntimeError{message: "Unrecognized type."})
+}
+
+// Get an instance, or throw an error
+func expectInstance(value any) *Instance {
+	if instance, ok := value.(*Instance); ok {
+		return instance
+	}
+	panic(RuntimeError{message: "Expect instance."})
+}
+
+// Get the name of a public field, or throw an error
+func expectFieldName(value any) string {
+	name := expectString(value)
+	if isPrivate(name) {
+		panic(RuntimeError{message: "Can't access private member '" + name + "' by name."})
+	}
+	return name
+}
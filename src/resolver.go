@@ -18,8 +18,19 @@ const (
 type Resolver struct {
 	interpreter     *Interpreter
 	scopes          []map[string]bool
+	constants       []map[string]bool // Names declared with "let" in each scope
 	currentFunction functionType
-	inClass         bool
+	currentDecl     *FunctionStmt // Innermost function declaration, which is marked if it yields
+	currentClass    *classScope   // nil outside of a class body
+	inStatic        bool          // Whether a static member is being resolved, where "this" is not allowed
+	tailCalls       []*ReturnStmt // Returns of calls in the innermost function, marked once it is known not to yield
+}
+
+// Private members of a class body, to check that every private member used is declared
+type classScope struct {
+	declaration *ClassStmt
+	declared    map[string]bool // Private methods, and private fields that are assigned
+	used        []Token
 }
 
 // Test for interface implementation
@@ -28,27 +39,84 @@ var _ StmtVisitor = &Resolver{}
 
 // Entry point for resolution
 func (r *Resolver) resolve(statements []Stmt) {
+	if len(r.scopes) == 0 {
+		r.declareConstants(statements)
+	}
 	for _, statement := range statements {
 		r.resolveStmt(statement)
 	}
 }
 
+// Mark the global constants before resolving anything, so functions declared above a constant can't assign to it
+func (r *Resolver) declareConstants(statements []Stmt) {
+	for _, statement := range statements {
+		names := []Token{}
+		isConstant := false
+		switch statement := statement.(type) {
+		case *VarStmt:
+			names, isConstant = varNames(statement), statement.constant
+		case *FunctionStmt:
+			names = []Token{statement.name}
+		case *ClassStmt:
+			names = []Token{statement.name}
+		case *TraitStmt:
+			names = []Token{statement.name}
+		}
+
+		for _, name := range names {
+			if r.interpreter.constants[name.lexeme] {
+				reportToken(name, "Can't redeclare constant '"+name.lexeme+"'.")
+			}
+			if isConstant {
+				r.interpreter.constants[name.lexeme] = true
+			}
+		}
+	}
+}
+
+// Throw an error if a variable being assigned was declared as a constant
+func (r *Resolver) checkAssignable(name Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, found := r.scopes[i][name.lexeme]; found {
+			if r.constants[i][name.lexeme] {
+				reportToken(name, "Can't assign to constant '"+name.lexeme+"'.")
+			}
+			return
+		}
+	}
+	if r.interpreter.constants[name.lexeme] {
+		reportToken(name, "Can't assign to constant '"+name.lexeme+"'.")
+	}
+}
+
 // Pass resolver to statements and expressions
 func (r *Resolver) resolveStmt(stmt Stmt) {
+	if line, ok := r.interpreter.lines[stmt]; ok && r.interpreter.coverage != nil {
+		r.interpreter.coverage.addLine(line)
+	}
 	stmt.accept(r)
 }
 func (r *Resolver) resolveExpr(expr Expr) {
 	expr.accept(r)
 }
 
+// Note a statement or expression that branches, if coverage is being recorded
+func (r *Resolver) markBranch(node any, line int) {
+	if r.interpreter.coverage != nil {
+		r.interpreter.coverage.addBranch(node, line)
+	}
+}
+
 // Creates an additional scope one level deeper
 func (r *Resolver) beginScope() {
 	r.scopes = append(r.scopes, map[string]bool{})
+	r.constants = append(r.constants, map[string]bool{})
 }
 
 // Removes the most recent scope
 func (r *Resolver) endScope() {
 	r.scopes = r.scopes[0 : len(r.scopes)-1]
+	r.constants = r.constants[0 : len(r.constants)-1]
 }
 
 // Mark a variable as newly declared in the current scope
@@ -81,18 +149,28 @@ func (r *Resolver) resolveLocal(expr Expr, name Token) {
 
 // Defines the parameters in a new scope, and resolves the body
 func (r *Resolver) resolveFunction(function *FunctionStmt, ftype functionType) {
-	enclosingFunction := r.currentFunction
-	r.currentFunction = ftype
+	enclosingFunction, enclosingDecl, enclosingTails := r.currentFunction, r.currentDecl, r.tailCalls
+	r.currentFunction, r.currentDecl, r.tailCalls = ftype, function, nil
 	r.beginScope()
 
 	for _, param := range function.params {
-		r.declare(param)
-		r.define(param)
+		r.declare(param.name)
+		if param.defaultValue != nil {
+			r.resolveExpr(param.defaultValue)
+		}
+		r.define(param.name)
 	}
 	r.resolve(function.body)
 
+	// A generator's returns end the generator rather than a call, so they can't be tail calls
+	if !function.isGenerator {
+		for _, stmt := range r.tailCalls {
+			stmt.isTailCall = true
+		}
+	}
+
 	r.endScope()
-	r.currentFunction = enclosingFunction
+	r.currentFunction, r.currentDecl, r.tailCalls = enclosingFunction, enclosingDecl, enclosingTails
 }
 
 // Resolve the statements
@@ -105,11 +183,44 @@ func (r *Resolver) visitBlockStmt(stmt *BlockStmt) any {
 
 // Defines the class, defines "this" in a new scope, and resolves the methods
 func (r *Resolver) visitClassStmt(stmt *ClassStmt) any {
-	enclosingClass := r.inClass
-	r.inClass = true
+	enclosingClass, enclosingStatic := r.currentClass, r.inStatic
+	r.currentClass = &classScope{declaration: stmt, declared: map[string]bool{}, used: []Token{}}
+	for _, members := range [][]*FunctionStmt{stmt.methods, stmt.setters, stmt.staticMethods} {
+		for _, member := range members {
+			r.currentClass.declared[member.name.lexeme] = true
+		}
+	}
+	for _, field := range stmt.staticFields {
+		r.currentClass.declared[field.name.lexeme] = true
+	}
 
 	r.declare(stmt.name)
 	r.define(stmt.name)
+	for _, trait := range stmt.traits {
+		r.resolveExpr(trait)
+	}
+
+	// Static members and decorators have no instance, so they are resolved outside of the scope with "this"
+	r.inStatic = true
+	for _, members := range [][]*FunctionStmt{stmt.methods, stmt.setters} {
+		for _, member := range members {
+			for _, decorator := range member.decorators {
+				r.resolveExpr(decorator)
+			}
+		}
+	}
+	for _, method := range stmt.staticMethods {
+		for _, decorator := range method.decorators {
+			r.resolveExpr(decorator)
+		}
+		r.resolveFunction(method, METHOD)
+	}
+	for _, field := range stmt.staticFields {
+		if field.initializer != nil {
+			r.resolveExpr(field.initializer)
+		}
+	}
+	r.inStatic = false
 
 	r.beginScope()
 	r.scopes[len(r.scopes)-1]["this"] = true
@@ -122,10 +233,21 @@ func (r *Resolver) visitClassStmt(stmt *ClassStmt) any {
 
 		r.resolveFunction(method, declaration)
 	}
+	for _, setter := range stmt.setters {
+		r.resolveFunction(setter, METHOD)
+	}
 
 	r.endScope()
 
-	r.inClass = enclosingClass
+	// Private fields can be assigned anywhere in the class body, so they are checked at the end
+	for _, name := range r.currentClass.used {
+		if !r.currentClass.declared[name.lexeme] {
+			reportToken(name, "Undefined private member '"+name.lexeme+
+				"' in class '"+stmt.name.lexeme+"'.")
+		}
+	}
+
+	r.currentClass, r.inStatic = enclosingClass, enclosingStatic
 	return nil
 }
 
@@ -135,8 +257,31 @@ func (r *Resolver) visitExpressionStmt(stmt *ExpressionStmt) any {
 	return nil
 }
 
-// Defines and resolves the function
+// Resolves the iterable, then the pattern and the body in a new scope with the loop variables
+// The pattern is matched inside each iteration's environment, so it is resolved inside the scope too
+func (r *Resolver) visitForInStmt(stmt *ForInStmt) any {
+	r.resolveExpr(stmt.iterable)
+
+	r.beginScope()
+	names := []Token{stmt.name}
+	if stmt.pattern != nil {
+		names = stmt.pattern.bindings()
+		r.resolvePattern(stmt.pattern)
+	}
+	for _, name := range names {
+		r.declare(name)
+		r.define(name)
+	}
+	r.resolveStmt(stmt.body)
+	r.endScope()
+	return nil
+}
+
+// Resolves the decorators, then defines and resolves the function
 func (r *Resolver) visitFunctionStmt(stmt *FunctionStmt) any {
+	for _, decorator := range stmt.decorators {
+		r.resolveExpr(decorator)
+	}
 	r.declare(stmt.name)
 	r.define(stmt.name)
 
@@ -146,6 +291,7 @@ func (r *Resolver) visitFunctionStmt(stmt *FunctionStmt) any {
 
 // Resolves the condition and branches
 func (r *Resolver) visitIfStmt(stmt *IfStmt) any {
+	r.markBranch(stmt, r.interpreter.lines[stmt])
 	r.resolveExpr(stmt.condition)
 	r.resolveStmt(stmt.thenBranch)
 	if stmt.elseBranch != nil {
@@ -167,17 +313,53 @@ func (r *Resolver) visitReturnStmt(stmt *ReturnStmt) any {
 		}
 
 		r.resolveExpr(stmt.value)
+		if _, ok := stmt.value.(*CallExpr); ok && r.currentFunction != INITIALIZER {
+			r.tailCalls = append(r.tailCalls, stmt)
+		}
 	}
 	return nil
 }
 
-// Declares, then resolves the value, then defines
-func (r *Resolver) visitVarStmt(stmt *VarStmt) any {
+// Defines the trait, and resolves the default methods in a new scope with "this"
+func (r *Resolver) visitTraitStmt(stmt *TraitStmt) any {
 	r.declare(stmt.name)
+	r.define(stmt.name)
+
+	// Traits have no private members, so there is no declaration to check them against
+	enclosingClass, enclosingStatic := r.currentClass, r.inStatic
+	r.currentClass, r.inStatic = &classScope{declared: map[string]bool{}, used: []Token{}}, false
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+	for _, method := range stmt.methods {
+		r.resolveFunction(method, METHOD)
+	}
+	r.endScope()
+
+	r.currentClass, r.inStatic = enclosingClass, enclosingStatic
+	return nil
+}
+
+// Declares, then resolves the value, then defines (every variable of a pattern)
+func (r *Resolver) visitVarStmt(stmt *VarStmt) any {
+	names := []Token{stmt.name}
+	if stmt.pattern != nil {
+		names = stmt.pattern.bindings()
+		r.resolvePattern(stmt.pattern)
+	}
+
+	for _, name := range names {
+		r.declare(name)
+	}
 	if stmt.initializer != nil {
 		r.resolveExpr(stmt.initializer)
 	}
-	r.define(stmt.name)
+	for _, name := range names {
+		r.define(name)
+		if stmt.constant && len(r.scopes) != 0 {
+			r.constants[len(r.scopes)-1][name.lexeme] = true
+		}
+	}
 	return nil
 }
 
@@ -191,6 +373,7 @@ func (r *Resolver) visitWhileStmt(stmt *WhileStmt) any {
 // Resolves the value, then resolves the variable
 func (r *Resolver) visitAssignExpr(expr *AssignExpr) any {
 	r.resolveExpr(expr.value)
+	r.checkAssignable(expr.name)
 	r.resolveLocal(expr, expr.name)
 	return nil
 }
@@ -202,23 +385,84 @@ func (r *Resolver) visitBinaryExpr(expr *BinaryExpr) any {
 	return nil
 }
 
-// Resolves the callee and the arguments
+// Resolves the callee and the arguments (positional and keyword)
 func (r *Resolver) visitCallExpr(expr *CallExpr) any {
 	r.resolveExpr(expr.callee)
 
 	for _, argument := range expr.arguments {
 		r.resolveExpr(argument)
 	}
+	for _, keyword := range expr.keywords {
+		r.resolveExpr(keyword.value)
+	}
+
+	return nil
+}
+
+// Resolves the chain
+func (r *Resolver) visitChainExpr(expr *ChainExpr) any {
+	r.resolveExpr(expr.expression)
+	return nil
+}
 
+// Resolves the value, then resolves each target
+func (r *Resolver) visitDestructureExpr(expr *DestructureExpr) any {
+	r.resolveExpr(expr.value)
+	r.resolveTargets(expr.targets)
 	return nil
 }
 
+// Resolves destructuring targets, resolving variables without reading them
+func (r *Resolver) resolveTargets(targets []Expr) {
+	for _, target := range targets {
+		if spread, ok := target.(*SpreadExpr); ok {
+			target = spread.expression
+		}
+
+		switch target := target.(type) {
+		case *VariableExpr:
+			r.checkAssignable(target.Token)
+			r.resolveLocal(target, target.Token)
+		case *GetExpr:
+			r.resolveExpr(target.object)
+			r.resolvePrivate(target, target.name, true)
+		case *IndexExpr:
+			r.resolveExpr(target.indexee)
+			r.resolveExpr(target.start)
+		case *ListExpr:
+			r.resolveTargets(target.elements)
+		}
+	}
+}
+
 // Resolves the object
 func (r *Resolver) visitGetExpr(expr *GetExpr) any {
 	r.resolveExpr(expr.object)
+	r.resolvePrivate(expr, expr.name, false)
 	return nil
 }
 
+// Check that a private member is used inside a class, and note which class it belongs to
+func (r *Resolver) resolvePrivate(expr Expr, name Token, isAssigned bool) {
+	if name.tokenType != PRIVATE_NAME {
+		return
+	}
+	if r.currentClass == nil {
+		reportToken(name, "Can't use private member '"+name.lexeme+"' outside of a class.")
+		return
+	} else if r.currentClass.declaration == nil {
+		reportToken(name, "Can't use private member '"+name.lexeme+"' in a trait.")
+		return
+	}
+
+	if isAssigned {
+		r.currentClass.declared[name.lexeme] = true
+	} else {
+		r.currentClass.used = append(r.currentClass.used, name)
+	}
+	r.interpreter.resolveOwner(expr, r.currentClass.declaration)
+}
+
 // Resolves the expression
 func (r *Resolver) visitGroupingExpr(expr *GroupingExpr) any {
 	r.resolveExpr(expr.expression)
@@ -250,11 +494,58 @@ func (*Resolver) visitLiteralExpr(*LiteralExpr) any {
 
 // Resolves both operands
 func (r *Resolver) visitLogicalExpr(expr *LogicalExpr) any {
+	r.markBranch(expr, expr.operator.line)
 	r.resolveExpr(expr.left)
 	r.resolveExpr(expr.right)
 	return nil
 }
 
+// Resolves the subject, then resolves each arm in its own scope with its bindings
+func (r *Resolver) visitMatchExpr(expr *MatchExpr) any {
+	r.resolveExpr(expr.subject)
+
+	for _, arm := range expr.arms {
+		r.beginScope()
+		for _, name := range arm.bindings {
+			r.declare(name)
+			r.define(name)
+		}
+		for _, pattern := range arm.patterns {
+			r.resolvePattern(pattern)
+		}
+
+		if arm.guard != nil {
+			r.resolveExpr(arm.guard)
+		}
+		if arm.body != nil {
+			r.resolveStmt(arm.body)
+		} else {
+			r.resolveExpr(arm.value)
+		}
+		r.endScope()
+	}
+	return nil
+}
+
+// Resolves any expressions within a pattern (only the classes of class patterns)
+func (r *Resolver) resolvePattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *ListPattern:
+		for _, element := range pattern.elements {
+			r.resolvePattern(element)
+		}
+	case *ClassPattern:
+		r.resolveExpr(pattern.class)
+		for _, arg := range pattern.args {
+			r.resolvePattern(arg)
+		}
+	case *ObjectPattern:
+		for _, field := range pattern.fields {
+			r.resolvePattern(field.pattern)
+		}
+	}
+}
+
 // Resolves indexee, index, and value
 func (r *Resolver) visitReplaceExpr(expr *ReplaceExpr) any {
 	r.resolveExpr(expr.indexee)
@@ -267,11 +558,25 @@ func (r *Resolver) visitReplaceExpr(expr *ReplaceExpr) any {
 func (r *Resolver) visitSetExpr(expr *SetExpr) any {
 	r.resolveExpr(expr.object)
 	r.resolveExpr(expr.value)
+	r.resolvePrivate(expr, expr.name, true)
+	return nil
+}
+
+// Resolves the call
+func (r *Resolver) visitSpawnExpr(expr *SpawnExpr) any {
+	r.resolveExpr(expr.call)
+	return nil
+}
+
+// Resolves the spread expression
+func (r *Resolver) visitSpreadExpr(expr *SpreadExpr) any {
+	r.resolveExpr(expr.expression)
 	return nil
 }
 
 // Resolves the condition and both values
 func (r *Resolver) visitTernaryExpr(expr *TernaryExpr) any {
+	r.markBranch(expr, expr.operator.line)
 	r.resolveExpr(expr.condition)
 	r.resolveExpr(expr.trueValue)
 	r.resolveExpr(expr.falseValue)
@@ -280,11 +585,16 @@ func (r *Resolver) visitTernaryExpr(expr *TernaryExpr) any {
 
 // Checks for location error and resolves "this"
 func (r *Resolver) visitThisExpr(expr *ThisExpr) any {
-	if !r.inClass {
+	if r.currentClass == nil {
 		reportToken(expr.Token,
 			"Can't use 'this' outside of a class.")
 		return nil
 	}
+	if r.inStatic {
+		reportToken(expr.Token,
+			"Can't use 'this' in a static member.")
+		return nil
+	}
 
 	r.resolveLocal(expr, expr.Token)
 	return nil
@@ -307,3 +617,19 @@ func (r *Resolver) visitVariableExpr(expr *VariableExpr) any {
 	r.resolveLocal(expr, expr.Token)
 	return nil
 }
+
+// Marks the enclosing function as a generator, and resolves the value
+func (r *Resolver) visitYieldExpr(expr *YieldExpr) any {
+	if r.currentFunction == NONE {
+		reportToken(expr.keyword, "Can't yield from top-level code.")
+	} else if r.currentFunction == INITIALIZER {
+		reportToken(expr.keyword, "Can't yield from an initializer.")
+	} else {
+		r.currentDecl.isGenerator = true
+	}
+
+	if expr.value != nil {
+		r.resolveExpr(expr.value)
+	}
+	return nil
+}
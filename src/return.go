@@ -5,3 +5,10 @@ package main
 type Return struct {
 	value any
 }
+
+// Thrown in place of a Return when a function returns a call to another function,
+// so that the call can run in place of the function rather than nested inside it
+type TailCall struct {
+	function  *Function
+	arguments []any
+}
@@ -0,0 +1,151 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits on running untrusted code, where a zero value means no limit
+type Limits struct {
+	maxSteps int64         // Statements that can be executed
+	timeout  time.Duration // Wall-clock time that a run can take
+	maxDepth int           // Function calls that can be nested
+	maxAlloc int64         // List and string elements that can be allocated over a run
+	maxTasks int64         // Spawned tasks that can be running at once
+	noIO     bool          // Whether the file system library is left out of the globals
+}
+
+// Exit codes for each kind of exceeded limit, so the host can tell them apart from other errors
+var limitExitCodes = map[limitKind]int{
+	STEP_LIMIT:  3,
+	TIME_LIMIT:  4,
+	DEPTH_LIMIT: 5,
+	ALLOC_LIMIT: 6,
+	TASK_LIMIT:  7,
+}
+
+// Tracks a run against its limits
+type sandbox struct {
+	limits    Limits
+	ctx       context.Context // Cancelled once the timeout runs out, or once a task exceeds a limit
+	cancel    context.CancelFunc
+	steps     int64
+	allocated int64
+	tasks     int64 // Spawned tasks that haven't finished yet
+	lock      sync.Mutex
+	failure   *LimitError // Limit exceeded by a task, which ends the whole run
+}
+
+// Build a sandbox for a run with the given limits, starting its timeout
+func newSandbox(limits Limits) *sandbox {
+	s := &sandbox{limits: limits}
+	if limits.timeout > 0 {
+		s.ctx, s.cancel = context.WithTimeout(context.Background(), limits.timeout)
+	} else {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
+	return s
+}
+
+// Count an executed statement, checking the step limit and the timeout
+func (s *sandbox) step() {
+	if s.limits.maxSteps > 0 && atomic.AddInt64(&s.steps, 1) > s.limits.maxSteps {
+		panic(LimitError{kind: STEP_LIMIT,
+			message: "Exceeded step limit of " + strconv.FormatInt(s.limits.maxSteps, 10) + "."})
+	}
+	select {
+	case <-s.ctx.Done():
+		s.interrupted()
+	default:
+	}
+}
+
+// Channel that is closed once the run is cut short, for operations that block
+func (s *sandbox) cancelled() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Record a limit exceeded by a task, cutting short the rest of the run
+// Only the first is kept, since the run ends with it
+func (s *sandbox) fail(err LimitError) {
+	s.lock.Lock()
+	if s.failure == nil {
+		s.failure = &err
+	}
+	s.lock.Unlock()
+	s.cancel()
+}
+
+// Throw the error that cut the run short, which is either a task's exceeded limit or running out of time
+func (s *sandbox) interrupted() {
+	s.checkFailure()
+	panic(LimitError{kind: TIME_LIMIT, message: "Exceeded time limit of " + s.limits.timeout.String() + "."})
+}
+
+// Throw the limit exceeded by a task, if there is one
+func (s *sandbox) checkFailure() {
+	s.lock.Lock()
+	failure := s.failure
+	s.lock.Unlock()
+	if failure != nil {
+		panic(*failure)
+	}
+}
+
+// Check the depth of a call about to be made
+func (s *sandbox) checkDepth(depth int, token Token) {
+	if s.limits.maxDepth > 0 && depth > s.limits.maxDepth {
+		panic(LimitError{kind: DEPTH_LIMIT, token: token,
+			message: "Exceeded call depth limit of " + strconv.Itoa(s.limits.maxDepth) + "."})
+	}
+}
+
+// Count elements allocated for a new list or string, checking the allocation limit
+func (s *sandbox) allocate(elements int, token Token) {
+	if s.limits.maxAlloc > 0 && atomic.AddInt64(&s.allocated, int64(elements)) > s.limits.maxAlloc {
+		panic(LimitError{kind: ALLOC_LIMIT, token: token,
+			message: "Exceeded allocation limit of " + strconv.FormatInt(s.limits.maxAlloc, 10) + " elements."})
+	}
+}
+
+// Count a task about to be spawned, checking the limit on tasks running at once
+func (s *sandbox) startTask(token Token) {
+	if tasks := atomic.AddInt64(&s.tasks, 1); s.limits.maxTasks > 0 && tasks > s.limits.maxTasks {
+		atomic.AddInt64(&s.tasks, -1)
+		panic(LimitError{kind: TASK_LIMIT, token: token,
+			message: "Exceeded limit of " + strconv.FormatInt(s.limits.maxTasks, 10) + " running tasks."})
+	}
+}
+
+// Stop counting a task once its call has finished
+func (s *sandbox) endTask() {
+	atomic.AddInt64(&s.tasks, -1)
+}
+
+// Number of elements in a newly built value, including the elements of any lists and strings nested inside it
+func allocationSize(value any) int {
+	sequence, ok := value.(Sequence)
+	if !ok {
+		return 0
+	}
+	size := len(sequence.list)
+	if !sequence.isString {
+		for _, element := range sequence.list {
+			size += allocationSize(element)
+		}
+	}
+	return size
+}
+
+// Wait for a duration, unless the timeout runs out first
+func (s *sandbox) sleep(duration time.Duration) {
+	select {
+	case <-time.After(duration):
+	case <-s.cancelled():
+		s.interrupted()
+	}
+}
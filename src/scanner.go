@@ -13,22 +13,32 @@ type Scanner struct {
 
 // List of keywords and the tokens that they evaluate to
 var keywords = map[string]tokenType{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"let":    LET,
-	"nil":    NIL,
-	"or":     OR,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":     AND,
+	"await":   AWAIT,
+	"case":    CASE,
+	"class":   CLASS,
+	"default": DEFAULT,
+	"else":    ELSE,
+	"false":   FALSE,
+	"for":     FOR,
+	"fun":     FUN,
+	"if":      IF,
+	"in":      IN,
+	"let":     LET,
+	"match":   MATCH,
+	"nil":     NIL,
+	"or":      OR,
+	"return":  RETURN,
+	"spawn":   SPAWN,
+	"static":  STATIC,
+	"super":   SUPER,
+	"this":    THIS,
+	"trait":   TRAIT,
+	"true":    TRUE,
+	"var":     VAR,
+	"while":   WHILE,
+	"with":    WITH,
+	"yield":   YIELD,
 }
 
 // Entry point for scanning
@@ -73,11 +83,17 @@ func (s *Scanner) scanToken() {
 	case ',':
 		s.addToken(COMMA)
 	case '.':
-		s.addToken(DOT)
-	case '?':
-		s.addToken(QUESTION)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(ELLIPSIS)
+		} else {
+			s.addToken(DOT)
+		}
 	case ';':
 		s.addToken(SEMICOLON)
+	case '@':
+		s.addToken(AT)
 
 	// Multi-character tokens
 	case '!':
@@ -89,6 +105,8 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(ARROW)
 		} else {
 			s.addToken(EQUAL)
 		}
@@ -140,8 +158,38 @@ func (s *Scanner) scanToken() {
 		} else {
 			s.addToken(STAR)
 		}
+	case '~':
+		if s.match('/') {
+			if s.match('=') {
+				s.addToken(TILDE_SLASH_EQUAL)
+			} else {
+				s.addToken(TILDE_SLASH)
+			}
+		} else {
+			reportLexeme(s.line, s.getCol(), string(c), "Expect '/' after '~'.")
+		}
+	case '?':
+		if s.match('.') {
+			s.addToken(QUESTION_DOT)
+		} else if s.match('[') {
+			s.addToken(QUESTION_LEFT_BRACKET)
+		} else if s.match('?') {
+			if s.match('=') {
+				s.addToken(QUESTION_QUESTION_EQUAL)
+			} else {
+				s.addToken(QUESTION_QUESTION)
+			}
+		} else {
+			s.addToken(QUESTION)
+		}
 	case '"':
 		s.string()
+	case '#':
+		if isAlpha(s.peek()) {
+			s.privateName()
+		} else {
+			reportLexeme(s.line, s.getCol(), string(c), "Expect name after '#'.")
+		}
 
 	default:
 		if isDigit(c) {
@@ -205,23 +253,62 @@ func (s *Scanner) string() {
 
 // Scan all characters associated with a number and generate a token
 func (s *Scanner) number() {
-	for isDigit(s.peek()) {
-		s.advance()
+	// Integers with a base prefix can contain letters, so consume the whole word
+	if s.source[s.startChar] == '0' && isAlpha(s.peek()) && s.peek() != 'e' && s.peek() != 'E' {
+		for isAlphaNumeric(s.peek()) {
+			s.advance()
+		}
+		s.addNumber()
+		return
 	}
 
+	s.digits()
+
 	// Look for a fractional part.
 	if s.peek() == '.' && isDigit(s.peekNext()) {
 		// Consume the "."
 		s.advance()
+		s.digits()
+	}
 
-		for isDigit(s.peek()) {
+	// Look for an exponent, which may have a sign
+	if c := s.peek(); c == 'e' || c == 'E' {
+		if isDigit(s.peekNext()) || ((s.peekNext() == '+' || s.peekNext() == '-') &&
+			s.currChar+2 < len(s.source) && isDigit(s.source[s.currChar+2])) {
 			s.advance()
+			s.advance()
+			s.digits()
 		}
 	}
 
+	s.addNumber()
+}
+
+// Consume digits and the underscores that separate them
+func (s *Scanner) digits() {
+	for isDigit(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+}
+
+// Generate a number token, if the lexeme is a valid number
+func (s *Scanner) addNumber() {
+	lexeme := string(s.source[s.startChar:s.currChar])
+	if parseNumber(lexeme) == nil {
+		reportLexeme(s.line, s.getCol(), lexeme, "Invalid number literal.")
+		return
+	}
 	s.addToken(NUMBER)
 }
 
+// Scan all characters associated with a private name (after the '#') and generate a token
+func (s *Scanner) privateName() {
+	for isAlphaNumeric(s.peek()) {
+		s.advance()
+	}
+	s.addToken(PRIVATE_NAME)
+}
+
 // Scan all characters associated with an identifier and generate a token
 func (s *Scanner) identifier() {
 	for isAlphaNumeric(s.peek()) {
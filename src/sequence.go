@@ -1,6 +1,11 @@
 // Ward Jaeger, CS 403
 package main
 
+import "sync"
+
+// Guards the elements of every Sequence, since tasks can share them
+var sequenceLock sync.RWMutex
+
 // Data type that can be indexed/sliced/concatenated
 type Sequence struct {
 	list     []any
@@ -11,3 +16,56 @@ type Sequence struct {
 func (s *Sequence) size() int {
 	return len(s.list)
 }
+
+// Get a single element
+func (s *Sequence) element(index int) any {
+	sequenceLock.RLock()
+	defer sequenceLock.RUnlock()
+	return s.list[index]
+}
+
+// Replace a single element
+func (s *Sequence) setElement(index int, value any) {
+	sequenceLock.Lock()
+	defer sequenceLock.Unlock()
+	s.list[index] = value
+}
+
+// Get a copy of the elements from start up to stop
+func (s *Sequence) slice(start int, stop int) []any {
+	sequenceLock.RLock()
+	defer sequenceLock.RUnlock()
+	return append([]any{}, s.list[start:stop]...)
+}
+
+// Get a copy of all the elements
+func (s *Sequence) elements() []any {
+	return s.slice(0, s.size())
+}
+
+// Convert a WIXME string into a Go string
+func (s *Sequence) toGoString() string {
+	bytes := make([]byte, s.size())
+	for i, element := range s.elements() {
+		bytes[i] = element.(byte)
+	}
+	return string(bytes)
+}
+
+// Convert a Go string into a WIXME string
+func newString(str string) Sequence {
+	list := make([]any, len(str))
+	for i := 0; i < len(str); i++ {
+		list[i] = str[i]
+	}
+	return Sequence{list: list, isString: true}
+}
+
+// Convert a list of Go strings into a WIXME list of strings
+func newStringList(strs []string) Sequence {
+	list := make([]any, len(strs))
+	for i, str := range strs {
+		list[i] = newString(str)
+	}
+	return Sequence{list: list, isString: false}
+}
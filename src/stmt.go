@@ -11,9 +11,11 @@ type StmtVisitor interface {
 	visitBlockStmt(*BlockStmt) any
 	visitClassStmt(*ClassStmt) any
 	visitExpressionStmt(*ExpressionStmt) any
+	visitForInStmt(*ForInStmt) any
 	visitFunctionStmt(*FunctionStmt) any
 	visitIfStmt(*IfStmt) any
 	visitReturnStmt(*ReturnStmt) any
+	visitTraitStmt(*TraitStmt) any
 	visitVarStmt(*VarStmt) any
 	visitWhileStmt(*WhileStmt) any
 }
@@ -29,8 +31,12 @@ func (b *BlockStmt) accept(visitor StmtVisitor) any {
 
 // Declare a new class
 type ClassStmt struct {
-	name    Token
-	methods []*FunctionStmt
+	name          Token
+	traits        []*VariableExpr // Traits included with "with"
+	methods       []*FunctionStmt // Includes getters
+	setters       []*FunctionStmt
+	staticMethods []*FunctionStmt // Includes static getters
+	staticFields  []*VarStmt
 }
 
 func (c *ClassStmt) accept(visitor StmtVisitor) any {
@@ -46,11 +52,35 @@ func (e *ExpressionStmt) accept(visitor StmtVisitor) any {
 	return visitor.visitExpressionStmt(e)
 }
 
+// Execute the body once for each element of a list, string, or iterator, bound to a fresh variable
+type ForInStmt struct {
+	name     Token   // Variable name, or the first token of the pattern
+	pattern  Pattern // nil unless destructuring
+	iterable Expr
+	body     Stmt
+}
+
+func (f *ForInStmt) accept(visitor StmtVisitor) any {
+	return visitor.visitForInStmt(f)
+}
+
 // Define a new function/method
 type FunctionStmt struct {
-	name   Token
-	params []Token
-	body   []Stmt
+	name        Token
+	params      []Param
+	body        []Stmt
+	returnType  *TypeExpr // nil if the return type is not annotated
+	isGenerator bool      // Whether the body contains yield, which is set by the resolver
+	isGetter    bool      // Whether the method is called when its property is accessed, without parentheses
+	decorators  []Expr    // Callables that the function is passed through when it is defined, in the order written
+}
+
+// A single parameter of a function
+type Param struct {
+	name         Token
+	annotation   *TypeExpr // nil if the type is not annotated
+	defaultValue Expr      // nil if the parameter is required
+	isRest       bool      // Whether the parameter collects all remaining arguments into a list
 }
 
 func (f *FunctionStmt) accept(visitor StmtVisitor) any {
@@ -70,18 +100,33 @@ func (i *IfStmt) accept(visitor StmtVisitor) any {
 
 // Return a value from the current function
 type ReturnStmt struct {
-	keyword Token
-	value   Expr
+	keyword    Token
+	value      Expr
+	isTailCall bool // Whether the value is a call that replaces the function's frame, which is set by the resolver
 }
 
 func (r *ReturnStmt) accept(visitor StmtVisitor) any {
 	return visitor.visitReturnStmt(r)
 }
 
-// Define a new variable
+// Declare a new trait, with methods that including classes must implement, and default methods they receive
+type TraitStmt struct {
+	name     Token
+	required []*FunctionStmt // Only the names and parameters, without bodies
+	methods  []*FunctionStmt
+}
+
+func (t *TraitStmt) accept(visitor StmtVisitor) any {
+	return visitor.visitTraitStmt(t)
+}
+
+// Define a new variable (or constant), or destructure the initializer into several variables
 type VarStmt struct {
-	name        Token
+	name        Token     // Variable name, or the first token of the pattern
+	pattern     Pattern   // nil unless destructuring
+	annotation  *TypeExpr // nil if the type is not annotated, which it can't be when destructuring
 	initializer Expr
+	constant    bool // Whether it was declared with "let", so it can never be assigned
 }
 
 func (v *VarStmt) accept(visitor StmtVisitor) any {
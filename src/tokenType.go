@@ -8,6 +8,7 @@ type tokenType string
 // Go does not actually have enums, but constant values work as a substitute
 const (
 	// Single-character tokens.
+	AT            tokenType = "AT"
 	LEFT_PAREN    tokenType = "LEFT_PAREN"
 	RIGHT_PAREN   tokenType = "RIGHT_PAREN"
 	LEFT_BRACE    tokenType = "LEFT_BRACE"
@@ -17,52 +18,71 @@ const (
 	COLON         tokenType = "COLON"
 	COMMA         tokenType = "COMMA"
 	DOT           tokenType = "DOT"
-	QUESTION      tokenType = "QUESTION"
 	SEMICOLON     tokenType = "SEMICOLON"
 
-	// One or two character tokens.
-	BANG          tokenType = "BANG"
-	BANG_EQUAL    tokenType = "BANG_EQUAL"
-	EQUAL         tokenType = "EQUAL"
-	EQUAL_EQUAL   tokenType = "EQUAL_EQUAL"
-	GREATER       tokenType = "GREATER"
-	GREATER_EQUAL tokenType = "GREATER_EQUAL"
-	LESS          tokenType = "LESS"
-	LESS_EQUAL    tokenType = "LESS_EQUAL"
-	MINUS         tokenType = "MINUS"
-	MINUS_EQUAL   tokenType = "MINUS_EQUAL"
-	MINUS_MINUS   tokenType = "MINUS_MINUS"
-	PLUS          tokenType = "PLUS"
-	PLUS_EQUAL    tokenType = "PLUS_EQUAL"
-	PLUS_PLUS     tokenType = "PLUS_PLUS"
-	SLASH         tokenType = "SLASH"
-	SLASH_EQUAL   tokenType = "SLASH_EQUAL"
-	STAR          tokenType = "STAR"
-	STAR_EQUAL    tokenType = "STAR_EQUAL"
+	// Multi-character tokens.
+	ARROW                   tokenType = "ARROW"
+	BANG                    tokenType = "BANG"
+	BANG_EQUAL              tokenType = "BANG_EQUAL"
+	ELLIPSIS                tokenType = "ELLIPSIS"
+	EQUAL                   tokenType = "EQUAL"
+	EQUAL_EQUAL             tokenType = "EQUAL_EQUAL"
+	GREATER                 tokenType = "GREATER"
+	GREATER_EQUAL           tokenType = "GREATER_EQUAL"
+	LESS                    tokenType = "LESS"
+	LESS_EQUAL              tokenType = "LESS_EQUAL"
+	MINUS                   tokenType = "MINUS"
+	MINUS_EQUAL             tokenType = "MINUS_EQUAL"
+	MINUS_MINUS             tokenType = "MINUS_MINUS"
+	PLUS                    tokenType = "PLUS"
+	PLUS_EQUAL              tokenType = "PLUS_EQUAL"
+	PLUS_PLUS               tokenType = "PLUS_PLUS"
+	QUESTION                tokenType = "QUESTION"
+	QUESTION_DOT            tokenType = "QUESTION_DOT"
+	QUESTION_LEFT_BRACKET   tokenType = "QUESTION_LEFT_BRACKET"
+	QUESTION_QUESTION       tokenType = "QUESTION_QUESTION"
+	QUESTION_QUESTION_EQUAL tokenType = "QUESTION_QUESTION_EQUAL"
+	SLASH                   tokenType = "SLASH"
+	SLASH_EQUAL             tokenType = "SLASH_EQUAL"
+	STAR                    tokenType = "STAR"
+	STAR_EQUAL              tokenType = "STAR_EQUAL"
+	TILDE_SLASH             tokenType = "TILDE_SLASH"
+	TILDE_SLASH_EQUAL       tokenType = "TILDE_SLASH_EQUAL"
 
 	// Literals.
-	IDENTIFIER tokenType = "IDENTIFIER"
-	STRING     tokenType = "STRING"
-	NUMBER     tokenType = "NUMBER"
+	IDENTIFIER   tokenType = "IDENTIFIER"
+	PRIVATE_NAME tokenType = "PRIVATE_NAME"
+	STRING       tokenType = "STRING"
+	NUMBER       tokenType = "NUMBER"
 
 	// Keywords.
-	AND    tokenType = "AND"
-	BREAK  tokenType = "BREAK"
-	CLASS  tokenType = "CLASS"
-	ELSE   tokenType = "ELSE"
-	FALSE  tokenType = "FALSE"
-	FUN    tokenType = "FUN"
-	FOR    tokenType = "FOR"
-	IF     tokenType = "IF"
-	LET    tokenType = "LET"
-	NIL    tokenType = "NIL"
-	OR     tokenType = "OR"
-	RETURN tokenType = "RETURN"
-	SUPER  tokenType = "SUPER"
-	THIS   tokenType = "THIS"
-	TRUE   tokenType = "TRUE"
-	VAR    tokenType = "VAR"
-	WHILE  tokenType = "WHILE"
+	AND     tokenType = "AND"
+	AWAIT   tokenType = "AWAIT"
+	BREAK   tokenType = "BREAK"
+	CASE    tokenType = "CASE"
+	CLASS   tokenType = "CLASS"
+	DEFAULT tokenType = "DEFAULT"
+	ELSE    tokenType = "ELSE"
+	FALSE   tokenType = "FALSE"
+	FUN     tokenType = "FUN"
+	FOR     tokenType = "FOR"
+	IF      tokenType = "IF"
+	IN      tokenType = "IN"
+	LET     tokenType = "LET"
+	MATCH   tokenType = "MATCH"
+	NIL     tokenType = "NIL"
+	OR      tokenType = "OR"
+	RETURN  tokenType = "RETURN"
+	SPAWN   tokenType = "SPAWN"
+	STATIC  tokenType = "STATIC"
+	SUPER   tokenType = "SUPER"
+	THIS    tokenType = "THIS"
+	TRAIT   tokenType = "TRAIT"
+	TRUE    tokenType = "TRUE"
+	VAR     tokenType = "VAR"
+	WHILE   tokenType = "WHILE"
+	WITH    tokenType = "WITH"
+	YIELD   tokenType = "YIELD"
 
 	EOF tokenType = "EOF"
 )
@@ -0,0 +1,83 @@
+// Ward Jaeger, CS 403
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Logs function calls, and optionally statements, as a run executes
+type tracer struct {
+	lock       sync.Mutex
+	output     io.Writer
+	filter     string   // Glob that the names of traced functions must match, or empty to trace all of them
+	statements bool     // Whether each statement inside a traced function is logged as well
+	source     []string // Lines of the script, to show next to traced statements
+}
+
+// Check whether calls to a function with the given name are traced
+func (t *tracer) matches(name string) bool {
+	if t.filter == "" {
+		return true
+	}
+	matched, _ := path.Match(t.filter, name)
+	return matched
+}
+
+// Write a line of the trace, indented by the depth of the call stack
+func (t *tracer) log(depth int, message string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	fmt.Fprintln(t.output, strings.Repeat("  ", depth)+message)
+}
+
+// Log a function being called with its arguments, returning a function to log its return or error
+func (i *Interpreter) traceCall(f *Function, arguments []any) func(returnValue any, err any) {
+	name := f.declaration.name.lexeme
+	depth := len(i.traceNames)
+	i.traceNames = append(i.traceNames, name)
+	if !i.tracer.matches(name) {
+		return func(_ any, _ any) {
+			i.traceNames = i.traceNames[:depth]
+		}
+	}
+
+	args := []string{}
+	for _, argument := range arguments {
+		if !isMissing(argument) {
+			args = append(args, i.stringify(argument, true, f.declaration.name))
+		}
+	}
+	i.tracer.log(depth, "-> "+name+"("+strings.Join(args, ", ")+")")
+
+	return func(returnValue any, err any) {
+		i.traceNames = i.traceNames[:depth]
+		if runtimeErr, ok := err.(RuntimeError); ok {
+			i.tracer.log(depth, "<- "+name+" threw \""+runtimeErr.message+"\"")
+		} else if limitErr, ok := err.(LimitError); ok {
+			i.tracer.log(depth, "<- "+name+" threw \""+limitErr.message+"\"")
+		} else if err == nil {
+			i.tracer.log(depth, "<- "+name+" = "+i.stringify(returnValue, true, f.declaration.name))
+		}
+	}
+}
+
+// Log a statement about to be executed, if the innermost function call is traced
+func (i *Interpreter) traceStatement(stmt Stmt) {
+	line, ok := i.lines[stmt]
+	if !ok || !i.tracer.statements {
+		return
+	}
+	if i.tracer.filter != "" && (len(i.traceNames) == 0 || !i.tracer.matches(i.traceNames[len(i.traceNames)-1])) {
+		return
+	}
+
+	text := ""
+	if line <= len(i.tracer.source) {
+		text = strings.TrimSpace(i.tracer.source[line-1])
+	}
+	i.tracer.log(len(i.traceNames), fmt.Sprintf("line %d: %s", line, text))
+}
@@ -0,0 +1,90 @@
+// Ward Jaeger, CS 403
+package main
+
+// A set of methods that including classes must implement, along with default methods they receive
+type Trait struct {
+	name     string
+	required []*FunctionStmt // Only the names and parameters
+	methods  map[string]*Function
+}
+
+// String representation
+func (t *Trait) toString() string {
+	return t.name
+}
+
+// Names of all methods that a conforming class has, required or default
+func (t *Trait) methodNames() []string {
+	names := []string{}
+	for _, required := range t.required {
+		names = append(names, required.name.lexeme)
+	}
+	for name := range t.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Check whether a class or instance has all of the trait's methods
+// Instances may also have them as callable fields
+func (t *Trait) isImplementedBy(value any) bool {
+	for _, name := range t.methodNames() {
+		switch value := value.(type) {
+		case *Class:
+			if value.findMethod(name) == nil {
+				return false
+			}
+		case *Instance:
+			field, _ := value.getField(name)
+			if _, ok := field.(Callable); !ok && value.findMethod(name) == nil {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Add the default methods of a class's traits to its methods, unless the class defines them itself,
+// then check that every required method is defined and accepts the required number of arguments
+func (i *Interpreter) includeTraits(stmt *ClassStmt, methods map[string]*Function) {
+	traits := []*Trait{}
+	providers := map[string]*Trait{} // Trait that each default method came from
+	for _, expr := range stmt.traits {
+		trait, ok := i.evaluate(expr).(*Trait)
+		if !ok {
+			panic(RuntimeError{token: expr.Token, message: "Can only include traits."})
+		}
+		traits = append(traits, trait)
+
+		for name, method := range trait.methods {
+			if other, found := providers[name]; found {
+				panic(RuntimeError{token: stmt.name, message: "Class '" + stmt.name.lexeme +
+					"' gets conflicting method '" + name + "' from traits '" + other.name +
+					"' and '" + trait.name + "'."})
+			} else if _, found := methods[name]; !found {
+				methods[name] = method
+				providers[name] = trait
+			}
+		}
+	}
+
+	for _, trait := range traits {
+		for _, required := range trait.required {
+			name := required.name.lexeme
+			method, found := methods[name]
+			if !found {
+				panic(RuntimeError{token: stmt.name, message: "Class '" + stmt.name.lexeme +
+					"' must implement method '" + name + "' from trait '" + trait.name + "'."})
+			}
+
+			min, max := method.arity()
+			requiredMin, requiredMax := (&Function{declaration: required}).arity()
+			if min > requiredMin || (max != variadic && (requiredMax == variadic || max < requiredMax)) {
+				panic(RuntimeError{token: stmt.name, message: "Method '" + name + "' of class '" +
+					stmt.name.lexeme + "' must accept the arguments required by trait '" + trait.name + "'."})
+			}
+		}
+	}
+}
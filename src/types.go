@@ -0,0 +1,135 @@
+// Ward Jaeger, CS 403
+package main
+
+import "math/big"
+
+// Names of the types that are built into the language, rather than declared as classes or traits
+var builtinTypes = []string{"any", "nil", "bool", "number", "int", "float", "string", "list",
+	"function", "class", "trait", "instance"}
+
+// A type annotation on a variable, parameter, or return value
+type TypeExpr struct {
+	name     Token     // Built-in type, or the name of a class or trait
+	element  *TypeExpr // Type of the elements of a list, or nil if they can be anything
+	nullable bool      // Whether nil is allowed as well
+}
+
+// Write a type the way it is annotated
+func (t *TypeExpr) String() string {
+	str := t.name.lexeme
+	if t.element != nil {
+		str += "<" + t.element.String() + ">"
+	}
+	if t.nullable {
+		str += "?"
+	}
+	return str
+}
+
+// Write an actual type for a diagnostic against the type that was expected
+// Integers and floats are only told apart where the expected type tells them apart, and are a number otherwise
+func (t *TypeExpr) describe(expected *TypeExpr) string {
+	str := t.name.lexeme
+	if (str == "int" || str == "float") &&
+		(expected == nil || expected.name.lexeme != "int" && expected.name.lexeme != "float") {
+		str = "number"
+	}
+	if t.element != nil {
+		var expectedElement *TypeExpr
+		if expected != nil {
+			expectedElement = expected.element
+		}
+		str += "<" + t.element.describe(expectedElement) + ">"
+	}
+	if t.nullable {
+		str += "?"
+	}
+	return str
+}
+
+// Name of the type of a value, as it would be annotated
+func valueType(value any) string {
+	switch value := value.(type) {
+	case int64, *big.Int:
+		return "int"
+	case float64:
+		return "float"
+	case *Instance:
+		if value.Class != nil {
+			return value.Class.name
+		}
+	}
+	return typeName(value)
+}
+
+// Check whether a value has a type, looking up the names of classes and traits in an environment
+func (i *Interpreter) hasType(value any, t *TypeExpr, env *Environment) bool {
+	if value == nil && t.nullable {
+		return true
+	}
+
+	switch t.name.lexeme {
+	case "any":
+		return true
+	case "nil":
+		return value == nil
+	case "bool", "function", "class", "trait", "instance":
+		return typeName(value) == t.name.lexeme
+	case "number":
+		return isNumber(value)
+	case "int":
+		return isInteger(value)
+	case "float":
+		_, ok := value.(float64)
+		return ok
+	case "string":
+		sequence, ok := value.(Sequence)
+		return ok && sequence.isString
+	case "list":
+		sequence, ok := value.(Sequence)
+		if !ok || sequence.isString {
+			return false
+		}
+		if t.element != nil {
+			for _, element := range sequence.elements() {
+				if !i.hasType(element, t.element, env) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	switch named := env.get(t.name).(type) {
+	case *Class:
+		instance, ok := value.(*Instance)
+		return ok && instance.Class == named
+	case *Trait:
+		return named.isImplementedBy(value)
+	}
+	panic(RuntimeError{token: t.name, message: "'" + t.name.lexeme + "' is not a type."})
+}
+
+// Throw an error if the arguments bound to the annotated parameters of a function have the wrong types
+func (i *Interpreter) enforceParams(f *Function, env *Environment) {
+	for _, param := range f.declaration.params {
+		if param.annotation == nil {
+			continue
+		}
+		value := env.getAt(0, param.name.lexeme)
+		if !i.hasType(value, param.annotation, env) {
+			panic(RuntimeError{token: param.name, message: "Expect " + param.annotation.String() +
+				" for parameter '" + param.name.lexeme + "' but got " +
+				namedType(valueType(value)).describe(param.annotation) + "."})
+		}
+	}
+}
+
+// Throw an error if the value returned by a function does not have its annotated return type
+func (i *Interpreter) enforceReturn(f *Function, value any) {
+	if !i.hasType(value, f.declaration.returnType, f.closure) {
+		panic(RuntimeError{token: f.declaration.name, message: "Expect " + f.declaration.returnType.String() +
+			" to be returned from '" + f.declaration.name.lexeme + "' but got " +
+			namedType(valueType(value)).describe(f.declaration.returnType) + "."})
+	}
+}